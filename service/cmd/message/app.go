@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -19,26 +20,52 @@ import (
 // @BasePath	/api/v1
 func main() {
 	const op = "message.main"
-	ctx, cancel := context.WithCancel(context.Background())
 
 	cfg := config.MustLoad()
 
 	log := logger.New(cfg.Env)
 	log.Info("logger initialized", slog.String("op", op), slog.String("env", cfg.Env))
 
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(log, cfg, args[1:]); err != nil {
+			log.Error("failed to migrate database", logger.StringError(err))
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	application := app.New(log, cfg)
+	application.Repository.Run(ctx)
+	application.Partitions.Run(ctx)
 	application.EventConsumer.MustRun(ctx)
+	application.OutboxRelay.Run(ctx)
+	application.Sweeper.Run(ctx)
+	application.Retention.Run(ctx)
 	go application.RESTApp.MustRun()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 	<-stop
 
-	cancel()
 	application.RESTApp.Stop()
+	cancel()
+	application.OutboxRelay.Stop()
+	application.Sweeper.Stop()
+	application.Retention.Stop()
+	application.Partitions.Stop()
+	// Consumer отправляет события в топик недоставленных сообщений через Producer,
+	// поэтому останавливается раньше него.
+	application.EventConsumer.Stop()
+	// Repository закрывает подключения к репликам, поэтому останавливается после всех, кто читает данные.
+	application.Repository.Stop()
 	if err := application.EventProducer.Stop(); err != nil {
 		log.Error("failed to close event producer", logger.StringError(err))
 	}
 
-	application.EventConsumer.Stop()
+	if err := application.TracerProvider.Shutdown(context.Background()); err != nil {
+		log.Error("failed to shut down tracer provider", logger.StringError(err))
+	}
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/repository/postgresql"
+)
+
+// runMigrate выполняет подкоманду migrate: up применяет все непримененные миграции,
+// down откатывает последнюю примененную миграцию, status выводит состояние миграций.
+func runMigrate(log *slog.Logger, cfg *config.Config, args []string) error {
+	const op = "message.runMigrate"
+	log = log.With(slog.String("op", op))
+
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	migrator, err := postgresql.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			log.Error("failed to close database connection", logger.StringError(err))
+		}
+	}()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range applied {
+			log.Info("migration applied", slog.Uint64("version", m.Version), slog.String("name", m.Name))
+		}
+
+		log.Info("database schema is up to date", slog.Int("applied_count", len(applied)))
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+
+		if reverted == nil {
+			log.Info("no applied migrations to revert")
+			return nil
+		}
+
+		log.Info("migration reverted", slog.Uint64("version", reverted.Version), slog.String("name", reverted.Name))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, usage: migrate up|down|status", args[0])
+	}
+
+	return nil
+}
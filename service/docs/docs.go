@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/messages": {
             "get": {
-                "description": "Получение сообщений.",
+                "description": "Получение сообщений с фильтрацией и сортировкой.",
                 "consumes": [
                     "application/json"
                 ],
@@ -29,27 +29,109 @@ const docTemplate = `{
                 ],
                 "summary": "Получить сообщения",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы из предыдущего ответа. Если пуст - первая страница",
+                        "name": "cursor",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "Номер страницы. Если пуст - 0",
-                        "name": "page",
+                        "description": "Размер страницы. Ограничен максимальным значением из конфигурации",
+                        "name": "limit",
                         "in": "query"
                     },
                     {
-                        "type": "boolean",
-                        "description": "Статус - обработано. Если пусто - выводит все сообщения",
-                        "name": "processed",
+                        "type": "array",
+                        "items": {
+                            "enum": [
+                                "created",
+                                "queued",
+                                "processing",
+                                "processed",
+                                "failed",
+                                "cancelled"
+                            ],
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Статусы сообщений. Если пусто - выводит все сообщения",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "ID сообщений",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подстрока содержимого сообщения без учета регистра",
+                        "name": "content",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода создания включительно, RFC 3339",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода создания не включительно, RFC 3339",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода обработки включительно, RFC 3339",
+                        "name": "processed_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода обработки не включительно, RFC 3339",
+                        "name": "processed_to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created_at",
+                            "processed_at",
+                            "id"
+                        ],
+                        "type": "string",
+                        "description": "Поле сортировки. Если пусто - created_at",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Направление сортировки. Если пусто - asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Читать с основной базы данных, а не с реплики. Позволяет сразу прочитать только что созданное сообщение",
+                        "name": "X-Read-Primary",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Message"
-                            }
+                            "$ref": "#/definitions/get.response"
                         }
                     },
                     "400": {
@@ -93,6 +175,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/create.request"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности. Повторный запрос с тем же ключом возвращает ранее созданное сообщение",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -100,7 +188,217 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/create.response"
+                        },
+                        "headers": {
+                            "Idempotency-Replayed": {
+                                "type": "string",
+                                "description": "true, если сообщение создано ранее по тому же ключу идемпотентности"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/batch": {
+            "post": {
+                "description": "Создание новых сообщений пакетом. Каждое сообщение проверяется отдельно, сообщения\nс ошибками не создаются. Результаты возвращаются в порядке сообщений запроса.\nЕсли пакет больше допустимого размера, запрос отклоняется целиком до проверки сообщений.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Создать сообщения пакетом",
+                "parameters": [
+                    {
+                        "description": "Сообщения. Размер пакета ограничен значением из конфигурации",
+                        "name": "messages",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/createbatch.request"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/createbatch.response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/stats": {
+            "get": {
+                "description": "Получение количества сообщений и перцентилей задержки их обработки.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Получить статистику",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Период расчета задержки обработки, например 1h. Если пуст - значение из конфигурации",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Читать с основной базы данных, а не с реплики. Позволяет сразу прочитать только что созданное сообщение",
+                        "name": "X-Read-Primary",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/stats.response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/{id}": {
+            "get": {
+                "description": "Получение сообщения по ID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Получить сообщение",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Читать с основной базы данных, а не с реплики. Позволяет сразу прочитать только что созданное сообщение",
+                        "name": "X-Read-Primary",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
                         }
+                    }
+                }
+            }
+        },
+        "/messages/{id}/cancel": {
+            "post": {
+                "description": "Отмена обработки сообщения. Обработку можно отменить, пока обработчик не подтвердил получение сообщения.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Отменить сообщение",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -114,6 +412,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/mwerror.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/mwerror.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -145,19 +449,91 @@ const docTemplate = `{
                 }
             }
         },
+        "createbatch.item": {
+            "type": "object",
+            "required": [
+                "content"
+            ],
+            "properties": {
+                "content": {
+                    "type": "string",
+                    "maxLength": 256
+                }
+            }
+        },
+        "createbatch.itemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "createbatch.request": {
+            "type": "object",
+            "required": [
+                "messages"
+            ],
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/createbatch.item"
+                    }
+                }
+            }
+        },
+        "createbatch.response": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/createbatch.itemResult"
+                    }
+                }
+            }
+        },
+        "get.response": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Message"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
         "models.Message": {
             "type": "object",
             "properties": {
                 "content": {
                     "type": "string"
                 },
-                "createdAt": {
+                "created_at": {
+                    "type": "string"
+                },
+                "error_reason": {
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "processedAt": {
+                "processed_at": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "string"
                 }
             }
@@ -169,6 +545,52 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "stats.latency": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "p50_seconds": {
+                    "type": "number"
+                },
+                "p90_seconds": {
+                    "type": "number"
+                },
+                "p95_seconds": {
+                    "type": "number"
+                },
+                "p99_seconds": {
+                    "type": "number"
+                },
+                "window": {
+                    "type": "string"
+                }
+            }
+        },
+        "stats.response": {
+            "type": "object",
+            "properties": {
+                "cancelled": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "latency": {
+                    "$ref": "#/definitions/stats.latency"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "unprocessed": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
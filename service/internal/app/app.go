@@ -3,48 +3,84 @@ package app
 import (
 	"log/slog"
 
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
 	restapp "github.com/sedonn/message-service/internal/app/rest"
 	"github.com/sedonn/message-service/internal/config"
 	"github.com/sedonn/message-service/internal/event/kafka/consumer"
 	"github.com/sedonn/message-service/internal/event/kafka/producer"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+	"github.com/sedonn/message-service/internal/pkg/tracing"
 	"github.com/sedonn/message-service/internal/repository/postgresql"
+	"github.com/sedonn/message-service/internal/rest/handlers/health"
 	"github.com/sedonn/message-service/internal/services/message"
 )
 
 // App это микросервис сообщений.
 type App struct {
-	RESTApp       *restapp.App
-	EventProducer *producer.Producer
-	EventConsumer *consumer.Consumer
+	TracerProvider *sdktrace.TracerProvider
+	Repository     *postgresql.Repository
+	RESTApp        *restapp.App
+	EventProducer  *producer.Producer
+	OutboxRelay    *producer.Relay
+	Sweeper        *message.Sweeper
+	Retention      *message.Retention
+	Partitions     *message.Partitions
+	EventConsumer  *consumer.Consumer
 }
 
 // New создает новый микросервис сообщений.
 func New(log *slog.Logger, cfg *config.Config) *App {
 	const op = "app.New"
 
-	repository, err := postgresql.New(cfg)
+	tracerProvider, err := tracing.New(&cfg.Tracing)
+	if err != nil {
+		panic(err)
+	}
+
+	registry := metrics.NewRegistry()
+
+	repository, err := postgresql.New(log, cfg, metrics.NewDB(registry))
 	if err != nil {
 		panic(err)
 	}
 	log.Info("database connected", slog.String("op", op), slog.String("database", cfg.DB.Database))
 
-	producer, err := producer.New(&cfg.Kafka)
+	eventProducer, err := producer.New(&cfg.Kafka, metrics.NewProducer(registry))
 	if err != nil {
 		panic(err)
 	}
 
-	messageService := message.New(log, repository, repository, repository, producer)
+	messageService := message.New(log, &cfg.Messages, repository, repository, repository)
+
+	relay := producer.NewRelay(log, &cfg.Kafka, eventProducer, repository, messageService)
+
+	sweeper := message.NewSweeper(log, &cfg.Messages.Sweeper, messageService, repository, eventProducer)
+
+	retention := message.NewRetention(log, &cfg.Messages.Retention, repository, metrics.NewRetention(registry))
+
+	partitions := message.NewPartitions(log, &cfg.Messages.Partitions, repository)
 
-	consumer, err := consumer.New(log, &cfg.Kafka, messageService)
+	consumer, err := consumer.New(log, &cfg.Kafka, messageService, eventProducer, metrics.NewConsumer(registry))
 	if err != nil {
 		panic(err)
 	}
 
-	restApp := restapp.New(log, cfg.REST.Port, messageService)
+	restApp := restapp.New(log, &cfg.REST, messageService, registry, map[string]health.Checker{
+		"database":       repository,
+		"kafka_producer": eventProducer,
+		"kafka_consumer": consumer,
+	})
 
 	return &App{
-		RESTApp:       restApp,
-		EventProducer: producer,
-		EventConsumer: consumer,
+		TracerProvider: tracerProvider,
+		Repository:     repository,
+		RESTApp:        restApp,
+		EventProducer:  eventProducer,
+		OutboxRelay:    relay,
+		Sweeper:        sweeper,
+		Retention:      retention,
+		Partitions:     partitions,
+		EventConsumer:  consumer,
 	}
 }
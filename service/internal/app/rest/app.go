@@ -4,30 +4,69 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/sedonn/message-service/internal/config"
 	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+	"github.com/sedonn/message-service/internal/pkg/tracing"
+	"github.com/sedonn/message-service/internal/rest/handlers/health"
 	messagerest "github.com/sedonn/message-service/internal/rest/handlers/message"
 	"github.com/sedonn/message-service/internal/rest/handlers/swagdocs"
+	mwconsistency "github.com/sedonn/message-service/internal/rest/middleware/consistency"
 	mwerror "github.com/sedonn/message-service/internal/rest/middleware/error"
+	mwmetrics "github.com/sedonn/message-service/internal/rest/middleware/metrics"
 )
 
+// errShuttingDown возникает при проверке готовности во время остановки сервера.
+var errShuttingDown = errors.New("server is shutting down")
+
 // App это REST-сервер.
 type App struct {
-	log        *slog.Logger
-	httpServer *http.Server
-	port       int
+	log           *slog.Logger
+	httpServer    *http.Server
+	port          int
+	shutdownDelay time.Duration
+	shuttingDown  *atomic.Bool
 }
 
 // New создает новый REST-сервер.
-func New(log *slog.Logger, port int, m messagerest.Messenger) *App {
+//
+// checkers это зависимости, готовность которых проверяется хендлером /readyz.
+func New(
+	log *slog.Logger,
+	cfg *config.RESTConfig,
+	m messagerest.Messenger,
+	reg *prometheus.Registry,
+	checkers map[string]health.Checker,
+) *App {
+	a := &App{
+		log:           log,
+		port:          cfg.Port,
+		shutdownDelay: cfg.ShutdownDelay,
+		shuttingDown:  &atomic.Bool{},
+	}
+
 	router := gin.Default()
+	// Контекст запроса, в котором хранится span трассировки, передается в бизнес-логику через gin.Context.
+	router.ContextWithFallback = true
 
-	router.Use(mwerror.New())
+	router.Use(
+		otelgin.Middleware(tracing.ServiceName),
+		mwmetrics.New(metrics.NewHTTP(reg)),
+		mwerror.New(),
+		mwconsistency.New(),
+	)
 
 	api := router.Group("api")
 	{
@@ -38,17 +77,19 @@ func New(log *slog.Logger, port int, m messagerest.Messenger) *App {
 	}
 
 	swagdocs.BindTo(router)
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})))
 
-	srv := &http.Server{
-		Addr:    net.JoinHostPort("", strconv.Itoa(port)),
+	readinessCheckers := make(map[string]health.Checker, len(checkers)+1)
+	maps.Copy(readinessCheckers, checkers)
+	readinessCheckers["rest"] = health.CheckerFunc(a.check)
+	health.BindTo(router, cfg.ReadinessTimeout, readinessCheckers)
+
+	a.httpServer = &http.Server{
+		Addr:    net.JoinHostPort("", strconv.Itoa(cfg.Port)),
 		Handler: router.Handler(),
 	}
 
-	return &App{
-		log:        log,
-		httpServer: srv,
-		port:       port,
-	}
+	return a
 }
 
 // MustRun запускает REST-API сервер. Паникует при ошибке.
@@ -78,6 +119,12 @@ func (a *App) Stop() {
 	const op = "restapp.Stop"
 	log := a.log.With(slog.String("op", op), slog.String("address", a.httpServer.Addr))
 
+	a.shuttingDown.Store(true)
+	if a.shutdownDelay > 0 {
+		log.Info("REST-API server is not ready anymore, waiting before shutdown", slog.Duration("delay", a.shutdownDelay))
+		time.Sleep(a.shutdownDelay)
+	}
+
 	log.Info("shutting down REST-API server")
 	if err := a.httpServer.Shutdown(context.Background()); err != nil {
 		log.Error("failed to shut down REST-API server", logger.StringError(err))
@@ -85,3 +132,12 @@ func (a *App) Stop() {
 
 	log.Info("REST-API server is shut down")
 }
+
+// check возвращает ошибку, если сервер останавливается.
+func (a *App) check(context.Context) error {
+	if a.shuttingDown.Load() {
+		return errShuttingDown
+	}
+
+	return nil
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"slices"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -16,15 +18,21 @@ const (
 
 // Config хранит конфигурацию приложения.
 type Config struct {
-	Env   string      `yaml:"env" env-default:"local"`
-	REST  RESTConfig  `yaml:"rest"`
-	DB    DBConfig    `yaml:"db"`
-	Kafka KafkaConfig `yaml:"kafka"`
+	Env      string         `yaml:"env" env-default:"local"`
+	REST     RESTConfig     `yaml:"rest"`
+	DB       DBConfig       `yaml:"db"`
+	Kafka    KafkaConfig    `yaml:"kafka"`
+	Messages MessagesConfig `yaml:"messages"`
+	Tracing  TracingConfig  `yaml:"tracing"`
 }
 
 // RESTConfig хранит конфигурацию REST-API сервера.
 type RESTConfig struct {
 	Port int `yaml:"port" env:"REST_PORT"`
+	// ShutdownDelay это время между переходом в состояние неготовности и остановкой сервера,
+	// за которое оркестратор успевает перестать направлять запросы.
+	ShutdownDelay    time.Duration `yaml:"shutdown-delay" env:"REST_SHUTDOWN_DELAY" env-default:"0s"`
+	ReadinessTimeout time.Duration `yaml:"readiness-timeout" env:"REST_READINESS_TIMEOUT" env-default:"2s"`
 }
 
 // DBConfig хранит конфигурацию подключения к базе данных.
@@ -34,18 +42,245 @@ type DBConfig struct {
 	User     string `yaml:"username" env:"DB_USER" env-required:"true"`
 	Password string `yaml:"password" env:"DB_PASSWORD" env-required:"true"`
 	Database string `yaml:"database" env:"DB_NAME" env-required:"true"`
+	// SSLMode это режим TLS-подключения: disable, allow, prefer, require, verify-ca или verify-full.
+	SSLMode     string `yaml:"sslmode" env:"DB_SSLMODE" env-default:"disable"`
+	SSLRootCert string `yaml:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert     string `yaml:"sslcert" env:"DB_SSLCERT"`
+	SSLKey      string `yaml:"sslkey" env:"DB_SSLKEY"`
+	// ApplicationName это имя приложения, которое видно в pg_stat_activity.
+	ApplicationName string `yaml:"application-name" env:"DB_APPLICATION_NAME" env-default:"message-service"`
+	// StatementTimeout это максимальное время выполнения запроса. Нулевое значение не ограничивает запросы.
+	StatementTimeout time.Duration    `yaml:"statement-timeout" env:"DB_STATEMENT_TIMEOUT" env-default:"0s"`
+	Pool             DBPoolConfig     `yaml:"pool"`
+	Replicas         DBReplicasConfig `yaml:"replicas"`
+	// AutoMigrate включает применение миграций при запуске. Если выключено,
+	// микросервис не запускается с базой данных, в которой применены не все миграции.
+	AutoMigrate bool `yaml:"auto-migrate" env:"DB_AUTO_MIGRATE" env-default:"false"`
+}
+
+// DBPoolConfig хранит настройки пула подключений к базе данных.
+type DBPoolConfig struct {
+	MaxOpenConns    int           `yaml:"max-open-conns" env:"DB_POOL_MAX_OPEN_CONNS" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max-idle-conns" env:"DB_POOL_MAX_IDLE_CONNS" env-default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn-max-lifetime" env:"DB_POOL_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn-max-idle-time" env:"DB_POOL_CONN_MAX_IDLE_TIME" env-default:"5m"`
+}
+
+// DBReplicasConfig хранит настройки чтения с реплик базы данных.
+type DBReplicasConfig struct {
+	// Hosts это адреса реплик в формате host:port. Остальные параметры подключения совпадают с основной базой данных.
+	// Если адреса не заданы, чтение выполняется на основной базе данных. Для проверки состояния репликации
+	// пользователю базы данных нужны права роли pg_read_all_stats.
+	Hosts               []string      `yaml:"hosts" env:"DB_REPLICA_HOSTS" env-separator:","`
+	HealthCheckInterval time.Duration `yaml:"health-check-interval" env:"DB_REPLICA_HEALTH_CHECK_INTERVAL" env-default:"5s"`
+	HealthCheckTimeout  time.Duration `yaml:"health-check-timeout" env:"DB_REPLICA_HEALTH_CHECK_TIMEOUT" env-default:"1s"`
+	// MaxLag это отставание репликации, после которого чтение с реплики прекращается.
+	// Нулевое значение отключает проверку отставания.
+	MaxLag time.Duration `yaml:"max-lag" env:"DB_REPLICA_MAX_LAG" env-default:"10s"`
 }
 
 // KafkaConfig хранит конфигурацию брокеров и топиков Kafka.
 type KafkaConfig struct {
-	Brokers string      `yaml:"brokers" env:"KAFKA_BROKERS" env-required:"true"`
-	Topics  KafkaTopics `yaml:"topics"`
+	Brokers  string `yaml:"brokers" env:"KAFKA_BROKERS" env-required:"true"`
+	ClientID string `yaml:"client-id" env:"KAFKA_CLIENT_ID" env-default:"message-service"`
+	// Version это версия протокола Kafka, например 2.1.0.
+	Version  string          `yaml:"version" env:"KAFKA_VERSION" env-default:"2.1.0"`
+	Net      KafkaNetConfig  `yaml:"net"`
+	SASL     KafkaSASLConfig `yaml:"sasl"`
+	TLS      KafkaTLSConfig  `yaml:"tls"`
+	Topics   KafkaTopics     `yaml:"topics"`
+	Producer ProducerConfig  `yaml:"producer"`
+	Outbox   OutboxConfig    `yaml:"outbox"`
+	Consumer ConsumerConfig  `yaml:"consumer"`
+}
+
+// KafkaNetConfig хранит сетевые таймауты подключения к брокерам Kafka.
+type KafkaNetConfig struct {
+	DialTimeout  time.Duration `yaml:"dial-timeout" env:"KAFKA_DIAL_TIMEOUT" env-default:"30s"`
+	ReadTimeout  time.Duration `yaml:"read-timeout" env:"KAFKA_READ_TIMEOUT" env-default:"30s"`
+	WriteTimeout time.Duration `yaml:"write-timeout" env:"KAFKA_WRITE_TIMEOUT" env-default:"30s"`
+}
+
+// KafkaSASLConfig хранит настройки аутентификации SASL в Kafka.
+type KafkaSASLConfig struct {
+	// Mechanism это механизм SASL: PLAIN, SCRAM-SHA-256 или SCRAM-SHA-512. Если пуст, SASL не используется.
+	Mechanism string `yaml:"mechanism" env:"KAFKA_SASL_MECHANISM"`
+	User      string `yaml:"user" env:"KAFKA_SASL_USER"`
+	Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD"`
+}
+
+// KafkaTLSConfig хранит настройки TLS-подключения к брокерам Kafka.
+type KafkaTLSConfig struct {
+	Enabled bool `yaml:"enabled" env:"KAFKA_TLS_ENABLED" env-default:"false"`
+	// CAFile это путь к сертификатам удостоверяющих центров брокеров. Если пуст, используются системные.
+	CAFile string `yaml:"ca-file" env:"KAFKA_TLS_CA_FILE"`
+	// CertFile и KeyFile это пути к клиентскому сертификату и ключу для взаимной аутентификации.
+	CertFile           string `yaml:"cert-file" env:"KAFKA_TLS_CERT_FILE"`
+	KeyFile            string `yaml:"key-file" env:"KAFKA_TLS_KEY_FILE"`
+	ServerName         string `yaml:"server-name" env:"KAFKA_TLS_SERVER_NAME"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify" env:"KAFKA_TLS_INSECURE_SKIP_VERIFY" env-default:"false"`
 }
 
 // KafkaConfig хранит используемые приложением топики.
 type KafkaTopics struct {
-	ProcessingMessages string `yaml:"processing-messages" env:"KAFKA_TOPIC_PROCESSING_MESSAGES" env-required:"true"`
-	ProcessedMessages  string `yaml:"processed-messages" env:"KAFKA_TOPIC_PROCESSED_MESSAGES" env-required:"true"`
+	ProcessingMessages   string `yaml:"processing-messages" env:"KAFKA_TOPIC_PROCESSING_MESSAGES" env-required:"true"`
+	AcknowledgedMessages string `yaml:"acknowledged-messages" env:"KAFKA_TOPIC_ACKNOWLEDGED_MESSAGES" env-required:"true"`
+	ProcessedMessages    string `yaml:"processed-messages" env:"KAFKA_TOPIC_PROCESSED_MESSAGES" env-required:"true"`
+	DeadLetter           string `yaml:"dead-letter" env:"KAFKA_TOPIC_DEAD_LETTER" env-required:"true"`
+}
+
+// ProducerConfig хранит настройки отправки событий в Kafka.
+type ProducerConfig struct {
+	// Mode это режим отправки: sync или async.
+	Mode string `yaml:"mode" env:"KAFKA_PRODUCER_MODE" env-default:"sync"`
+	// RequiredAcks это уровень подтверждения записи брокерами: none, leader или all.
+	RequiredAcks string `yaml:"required-acks" env:"KAFKA_PRODUCER_REQUIRED_ACKS" env-default:"leader"`
+	// Compression это кодек сжатия пакетов: none, gzip, snappy, lz4 или zstd.
+	Compression string `yaml:"compression" env:"KAFKA_PRODUCER_COMPRESSION" env-default:"none"`
+	// FlushMessages и FlushBytes это количество и суммарный размер сообщений, при достижении которых
+	// пакет отправляется, не дожидаясь Linger. Нулевые значения не ограничивают пакет.
+	FlushMessages int `yaml:"flush-messages" env:"KAFKA_PRODUCER_FLUSH_MESSAGES" env-default:"0"`
+	FlushBytes    int `yaml:"flush-bytes" env:"KAFKA_PRODUCER_FLUSH_BYTES" env-default:"0"`
+	// Linger это максимальное время накопления пакета перед отправкой.
+	Linger time.Duration `yaml:"linger" env:"KAFKA_PRODUCER_LINGER" env-default:"0s"`
+	// Idempotent включает идемпотентную отправку. Требует RequiredAcks all.
+	Idempotent bool `yaml:"idempotent" env:"KAFKA_PRODUCER_IDEMPOTENT" env-default:"false"`
+	// Partitioner это способ выбора партиции: hash, random или roundrobin.
+	Partitioner     string        `yaml:"partitioner" env:"KAFKA_PRODUCER_PARTITIONER" env-default:"hash"`
+	MaxMessageBytes int           `yaml:"max-message-bytes" env:"KAFKA_PRODUCER_MAX_MESSAGE_BYTES" env-default:"1000000"`
+	Timeout         time.Duration `yaml:"timeout" env:"KAFKA_PRODUCER_TIMEOUT" env-default:"10s"`
+	RetryMax        int           `yaml:"retry-max" env:"KAFKA_PRODUCER_RETRY_MAX" env-default:"3"`
+}
+
+// OutboxConfig хранит настройки отправки событий из outbox в Kafka.
+type OutboxConfig struct {
+	PollInterval  time.Duration `yaml:"poll-interval" env:"KAFKA_OUTBOX_POLL_INTERVAL" env-default:"1s"`
+	BatchSize     int           `yaml:"batch-size" env:"KAFKA_OUTBOX_BATCH_SIZE" env-default:"100"`
+	RetryAttempts int           `yaml:"retry-attempts" env:"KAFKA_OUTBOX_RETRY_ATTEMPTS" env-default:"3"`
+	RetryBackoff  time.Duration `yaml:"retry-backoff" env:"KAFKA_OUTBOX_RETRY_BACKOFF" env-default:"500ms"`
+	// MaxAttempts это количество неудачных отправок события, после которого событие больше не отправляется.
+	MaxAttempts int `yaml:"max-attempts" env:"KAFKA_OUTBOX_MAX_ATTEMPTS" env-default:"10"`
+	// LockTimeout это время, на которое событие захватывается для отправки. Событие, захваченное остановившейся
+	// репликой, снова становится доступным для отправки по истечении этого времени.
+	// Должно превышать максимальное время отправки пакета со всеми повторными попытками, иначе событие
+	// может быть захвачено другой репликой до завершения отправки.
+	LockTimeout time.Duration `yaml:"lock-timeout" env:"KAFKA_OUTBOX_LOCK_TIMEOUT" env-default:"3m"`
+}
+
+// producerRetryBackoff это задержка sarama между повторными отправками пакета брокеру.
+// Значение не настраивается и совпадает со значением sarama по умолчанию.
+const producerRetryBackoff = 100 * time.Millisecond
+
+// MaxSendDuration возвращает максимальное время отправки пакета событий из outbox со всеми повторными попытками.
+//
+// Каждая попытка outbox ожидает накопления пакета и подтверждения брокера при каждой повторной отправке sarama,
+// между попытками outbox задержка удваивается.
+func (c *KafkaConfig) MaxSendDuration() time.Duration {
+	retryMax := time.Duration(max(c.Producer.RetryMax, 0))
+	attempt := (retryMax+1)*(c.Producer.Linger+c.Producer.Timeout) + retryMax*producerRetryBackoff
+
+	var d time.Duration
+	backoff := c.Outbox.RetryBackoff
+	for i := 1; i <= max(c.Outbox.RetryAttempts, 1); i++ {
+		d += attempt
+		if i > 1 {
+			d += backoff
+			backoff *= 2
+		}
+	}
+
+	return d
+}
+
+// ConsumerConfig хранит настройки группы потребителей и политику повторной обработки полученных событий.
+type ConsumerConfig struct {
+	Group string `yaml:"group" env:"KAFKA_CONSUMER_GROUP" env-default:"message-service"`
+	// RebalanceStrategy это стратегия распределения партиций: range, roundrobin или sticky.
+	RebalanceStrategy string `yaml:"rebalance-strategy" env:"KAFKA_CONSUMER_REBALANCE_STRATEGY" env-default:"range"`
+	// InitialOffset это смещение, с которого читается партиция без зафиксированного смещения: newest или oldest.
+	InitialOffset     string        `yaml:"initial-offset" env:"KAFKA_CONSUMER_INITIAL_OFFSET" env-default:"newest"`
+	SessionTimeout    time.Duration `yaml:"session-timeout" env:"KAFKA_CONSUMER_SESSION_TIMEOUT" env-default:"10s"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat-interval" env:"KAFKA_CONSUMER_HEARTBEAT_INTERVAL" env-default:"3s"`
+	RetryAttempts     int           `yaml:"retry-attempts" env:"KAFKA_CONSUMER_RETRY_ATTEMPTS" env-default:"3"`
+	RetryBackoff      time.Duration `yaml:"retry-backoff" env:"KAFKA_CONSUMER_RETRY_BACKOFF" env-default:"500ms"`
+}
+
+// MessagesConfig хранит конфигурацию бизнес-логики работы с сообщениями.
+type MessagesConfig struct {
+	Pagination  PaginationConfig  `yaml:"pagination"`
+	Stats       StatsConfig       `yaml:"stats"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Batch       BatchConfig       `yaml:"batch"`
+	Sweeper     SweeperConfig     `yaml:"sweeper"`
+	Retention   RetentionConfig   `yaml:"retention"`
+	Partitions  PartitionsConfig  `yaml:"partitions"`
+}
+
+// PaginationConfig хранит ограничения постраничной навигации по сообщениям.
+type PaginationConfig struct {
+	DefaultLimit int `yaml:"default-limit" env:"MESSAGES_PAGINATION_DEFAULT_LIMIT" env-default:"10"`
+	MaxLimit     int `yaml:"max-limit" env:"MESSAGES_PAGINATION_MAX_LIMIT" env-default:"100"`
+}
+
+// StatsConfig хранит настройки расчета статистики обработки сообщений.
+type StatsConfig struct {
+	DefaultWindow time.Duration `yaml:"default-window" env:"MESSAGES_STATS_DEFAULT_WINDOW" env-default:"24h"`
+	MaxWindow     time.Duration `yaml:"max-window" env:"MESSAGES_STATS_MAX_WINDOW" env-default:"720h"`
+}
+
+// IdempotencyConfig хранит настройки идемпотентного создания сообщений.
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl" env:"MESSAGES_IDEMPOTENCY_TTL" env-default:"24h"`
+}
+
+// BatchConfig хранит ограничения пакетного создания сообщений.
+type BatchConfig struct {
+	MaxSize int `yaml:"max-size" env:"MESSAGES_BATCH_MAX_SIZE" env-default:"1000"`
+}
+
+// SweeperConfig хранит настройки повторной отправки зависших сообщений.
+type SweeperConfig struct {
+	Interval time.Duration `yaml:"interval" env:"MESSAGES_SWEEPER_INTERVAL" env-default:"30s"`
+	// Timeout это время с последней отправки, после которого отправленное обработчику сообщение считается зависшим.
+	Timeout   time.Duration `yaml:"timeout" env:"MESSAGES_SWEEPER_TIMEOUT" env-default:"5m"`
+	BatchSize int           `yaml:"batch-size" env:"MESSAGES_SWEEPER_BATCH_SIZE" env-default:"100"`
+	// MaxAttempts это количество повторных отправок, после которого обработка сообщения считается неудачной.
+	MaxAttempts int `yaml:"max-attempts" env:"MESSAGES_SWEEPER_MAX_ATTEMPTS" env-default:"3"`
+}
+
+// RetentionConfig хранит настройки очистки завершенных сообщений, отправленных событий outbox
+// и истекших ключей идемпотентности.
+type RetentionConfig struct {
+	Enabled  bool          `yaml:"enabled" env:"MESSAGES_RETENTION_ENABLED" env-default:"false"`
+	Interval time.Duration `yaml:"interval" env:"MESSAGES_RETENTION_INTERVAL" env-default:"1h"`
+	// MaxAge это время с создания, после которого завершенное сообщение удаляется.
+	MaxAge time.Duration `yaml:"max-age" env:"MESSAGES_RETENTION_MAX_AGE" env-default:"720h"`
+	// BatchSize это количество строк, удаляемых одним запросом. Небольшие пакеты не блокируют таблицу надолго.
+	BatchSize int `yaml:"batch-size" env:"MESSAGES_RETENTION_BATCH_SIZE" env-default:"1000"`
+	// Archive включает перенос сообщений в архивную таблицу перед удалением.
+	Archive bool `yaml:"archive" env:"MESSAGES_RETENTION_ARCHIVE" env-default:"false"`
+	// OutboxMaxAge это время с отправки, после которого событие outbox удаляется.
+	OutboxMaxAge time.Duration `yaml:"outbox-max-age" env:"MESSAGES_RETENTION_OUTBOX_MAX_AGE" env-default:"168h"`
+}
+
+// PartitionsConfig хранит настройки обслуживания месячных секций таблицы сообщений.
+type PartitionsConfig struct {
+	Interval time.Duration `yaml:"interval" env:"MESSAGES_PARTITIONS_INTERVAL" env-default:"24h"`
+	// Premake это количество месяцев вперед, для которых секции создаются заранее.
+	Premake int `yaml:"premake" env:"MESSAGES_PARTITIONS_PREMAKE" env-default:"3"`
+	// MaxAge это время, после которого секция, все сообщения которой созданы раньше, отсоединяется от таблицы.
+	// Секции с необработанными сообщениями не отсоединяются. Нулевое значение отключает отсоединение секций.
+	MaxAge time.Duration `yaml:"max-age" env:"MESSAGES_PARTITIONS_MAX_AGE" env-default:"0s"`
+	// Drop включает удаление отсоединенных секций. Иначе они остаются отдельными таблицами.
+	Drop bool `yaml:"drop" env:"MESSAGES_PARTITIONS_DROP" env-default:"false"`
+}
+
+// TracingConfig хранит конфигурацию экспорта трассировок OpenTelemetry.
+type TracingConfig struct {
+	// Exporter это тип экспортера: none, stdout или otlp.
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	SampleRatio float64 `yaml:"sample-ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
 }
 
 // MustLoad загружает текущую конфигурацию микросервиса на основе пути к файлу конфигурации,
@@ -81,6 +316,10 @@ func MustLoadByPath(path string) *Config {
 		panic("unknown env: " + cfg.Env)
 	}
 
+	if err := validateOutbox(&cfg.Kafka); err != nil {
+		panic("invalid outbox config: " + err.Error())
+	}
+
 	return &cfg
 }
 
@@ -107,3 +346,12 @@ func validateEnv(env string) bool {
 
 	return slices.Contains(envTypes, env)
 }
+
+// validateOutbox проверяет, что событие остается захваченным на все время его отправки.
+func validateOutbox(cfg *KafkaConfig) error {
+	if d := cfg.MaxSendDuration(); cfg.Outbox.LockTimeout <= d {
+		return fmt.Errorf("lock timeout %s must exceed max send duration %s", cfg.Outbox.LockTimeout, d)
+	}
+
+	return nil
+}
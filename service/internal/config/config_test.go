@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateOutbox(t *testing.T) {
+	// Каждая попытка outbox длится до 4 * 10s + 3 * 100ms, между тремя попытками ожидание 500ms и 1s.
+	const maxSend = 3*(40*time.Second+300*time.Millisecond) + 1500*time.Millisecond
+
+	tests := []struct {
+		name        string
+		lockTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "lease exceeds send duration", lockTimeout: maxSend + time.Second},
+		{name: "lease equals send duration", lockTimeout: maxSend, wantErr: true},
+		{name: "lease is shorter than producer timeout", lockTimeout: 5 * time.Second, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &KafkaConfig{
+				Producer: ProducerConfig{Timeout: 10 * time.Second, RetryMax: 3},
+				Outbox: OutboxConfig{
+					RetryAttempts: 3,
+					RetryBackoff:  500 * time.Millisecond,
+					LockTimeout:   tt.lockTimeout,
+				},
+			}
+
+			if d := cfg.MaxSendDuration(); d != maxSend {
+				t.Errorf("MaxSendDuration() = %s, want %s", d, maxSend)
+			}
+			if err := validateOutbox(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateOutbox() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
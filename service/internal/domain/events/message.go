@@ -2,12 +2,35 @@ package events
 
 import "time"
 
+// Типы событий, которые сохраняются в outbox.
+const (
+	TypeStartProcessingMessage = "start-processing-message"
+)
+
+// Результаты обработки сообщения в событии о завершении обработки.
+const (
+	ProcessingStatusSucceeded = "succeeded"
+	ProcessingStatusFailed    = "failed"
+)
+
 type StartProcessingMessage struct {
 	ID      uint64 `json:"id"`
 	Content string `json:"content"`
 }
 
+// AcknowledgeProcessingMessage это событие о получении сообщения обработчиком.
+type AcknowledgeProcessingMessage struct {
+	ID uint64 `json:"id"`
+}
+
+// CompleteProcessingMessage это событие о завершении обработки сообщения.
+//
+// ProcessedAt это время завершения обработки на стороне обработчика. Если оно не указано,
+// используется время получения события. Если не указан Status, обработка считается успешной.
 type CompleteProcessingMessage struct {
 	StartProcessingMessage
-	ProcessedAt time.Time
+	ProcessedAt time.Time `json:"processed_at"`
+	Status      string    `json:"status"`
+	Result      *string   `json:"result,omitempty"`
+	Error       *string   `json:"error,omitempty"`
 }
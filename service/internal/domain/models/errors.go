@@ -0,0 +1,26 @@
+package models
+
+import "errors"
+
+var (
+	// ErrMessageNotFound возникает, если сообщение не найдено.
+	ErrMessageNotFound = errors.New("message not found")
+
+	// ErrMessageAlreadyProcessed возникает при повторном завершении обработки сообщения.
+	ErrMessageAlreadyProcessed = errors.New("message is already processed")
+
+	// ErrInvalidStatusTransition возникает при попытке недопустимого перехода статуса сообщения.
+	ErrInvalidStatusTransition = errors.New("invalid message status transition")
+
+	// ErrMessageStatusConflict возникает, если статус сообщения был изменен одновременно с обновлением.
+	ErrMessageStatusConflict = errors.New("message status was changed concurrently")
+
+	// ErrBatchTooLarge возникает, если размер пакета сообщений превышает допустимый.
+	ErrBatchTooLarge = errors.New("batch is too large")
+
+	// ErrInvalidCursor возникает, если курсор страницы поврежден.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrIdempotencyKeyConflict возникает, если ключ идемпотентности уже использован для другого запроса.
+	ErrIdempotencyKeyConflict = errors.New("idempotency key is already used for another request")
+)
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// IdempotencyKey связывает ключ идемпотентности запроса с созданным по нему сообщением.
+type IdempotencyKey struct {
+	Key string `gorm:"column:key;primaryKey;size:255"`
+	// RequestHash это хеш тела запроса, по которому выявляются разные запросы с одним ключом.
+	RequestHash string    `gorm:"column:request_hash;size:64;not null"`
+	MessageID   uint64    `gorm:"column:message_id;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;not null"`
+}
@@ -4,9 +4,45 @@ import (
 	"time"
 )
 
+// Статусы жизненного цикла сообщения.
+const (
+	// MessageStatusCreated это статус сохраненного сообщения, событие обработки которого еще не отправлено.
+	MessageStatusCreated = "created"
+	// MessageStatusQueued это статус сообщения, событие обработки которого отправлено в Kafka.
+	MessageStatusQueued = "queued"
+	// MessageStatusProcessing это статус сообщения, получение которого подтвердил обработчик.
+	MessageStatusProcessing = "processing"
+	// MessageStatusProcessed это статус успешно обработанного сообщения.
+	MessageStatusProcessed = "processed"
+	// MessageStatusFailed это статус сообщения, обработка которого завершилась ошибкой.
+	MessageStatusFailed = "failed"
+	// MessageStatusCancelled это статус сообщения, обработка которого отменена.
+	MessageStatusCancelled = "cancelled"
+)
+
+// Message хранит данные сообщения.
+// DispatchAttempts это количество успешных повторных отправок события старта обработки сообщения,
+// DispatchedAt это время первой отправки события обработчику или захвата сообщения для повторной отправки.
+// Поля отправки служебные и не возвращаются клиентам.
 type Message struct {
-	ID          uint64     `gorm:"column:id;primaryKey"`
-	Content     string     `gorm:"column:content;size:256"`
-	CreatedAt   time.Time  `gorm:"column:created_at"`
-	ProcessedAt *time.Time `gorm:"column:processed_at;default:null"`
+	ID               uint64     `gorm:"column:id;primaryKey" json:"id"`
+	Content          string     `gorm:"column:content;size:256" json:"content"`
+	Status           string     `gorm:"column:status;size:16;not null;default:created;index" json:"status"`
+	CreatedAt        time.Time  `gorm:"column:created_at" json:"created_at"`
+	ProcessedAt      *time.Time `gorm:"column:processed_at;default:null" json:"processed_at,omitempty"`
+	Result           *string    `gorm:"column:result;default:null" json:"result,omitempty"`
+	ErrorReason      *string    `gorm:"column:error_reason;default:null" json:"error_reason,omitempty"`
+	DispatchAttempts int        `gorm:"column:dispatch_attempts;not null;default:0" json:"-"`
+	DispatchedAt     *time.Time `gorm:"column:dispatched_at;default:null" json:"-"`
+}
+
+// MessageStatusUpdate хранит новый статус сообщения.
+// Время и результат обработки заполняются только при завершении обработки,
+// время отправки - только при постановке сообщения в очередь.
+type MessageStatusUpdate struct {
+	Status       string
+	ProcessedAt  *time.Time
+	Result       *string
+	ErrorReason  *string
+	DispatchedAt *time.Time
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent это событие, ожидающее отправки в брокер сообщений.
+type OutboxEvent struct {
+	ID      uint64 `gorm:"column:id;primaryKey"`
+	Type    string `gorm:"column:type;size:64;not null"`
+	Key     string `gorm:"column:key;size:64;not null"`
+	Payload []byte `gorm:"column:payload;type:jsonb;not null"`
+	// Headers это заголовки события, например контекст трассировки запроса, в котором оно создано.
+	Headers   map[string]string `gorm:"column:headers;type:jsonb;serializer:json"`
+	Attempts  int               `gorm:"column:attempts;not null;default:0"`
+	LastError *string           `gorm:"column:last_error;default:null"`
+	CreatedAt time.Time         `gorm:"column:created_at"`
+	SentAt    *time.Time        `gorm:"column:sent_at;default:null"`
+	// LockedUntil это время, до которого событие захвачено для отправки одной из реплик.
+	LockedUntil *time.Time `gorm:"column:locked_until;default:null"`
+	// FailedAt это время, когда событие перестало отправляться из-за исчерпания попыток или неизвестного типа.
+	FailedAt *time.Time `gorm:"column:failed_at;default:null"`
+}
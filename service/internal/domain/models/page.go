@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Cursor это позиция сообщения в упорядоченном списке.
+type Cursor struct {
+	// Sort это порядок списка, для которого создан курсор.
+	Sort MessageSort `json:"sort"`
+	// Value это значение поля сортировки. Пусто при сортировке по ID и для необработанных сообщений
+	// при сортировке по дате обработки.
+	Value *time.Time `json:"value,omitempty"`
+	ID    uint64     `json:"id"`
+}
+
+// Encode возвращает непрозрачное строковое представление курсора.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Matches проверяет, что курсор создан для указанного порядка сообщений.
+func (c Cursor) Matches(sort MessageSort) bool {
+	if c.Sort != sort {
+		return false
+	}
+
+	return sort.Field != SortByCreatedAt || c.Value != nil
+}
+
+// DecodeCursor восстанавливает курсор из строкового представления.
+func DecodeCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// PageRequest хранит параметры запроса страницы сообщений.
+type PageRequest struct {
+	// After это курсор последнего сообщения предыдущей страницы. Если пуст - запрашивается первая страница.
+	After *Cursor
+	Limit int
+}
+
+// MessagePage это страница сообщений.
+type MessagePage struct {
+	Items []Message
+	// Next это курсор для запроса следующей страницы. Если пуст - страница последняя.
+	Next *Cursor
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Поля, по которым могут быть упорядочены сообщения.
+const (
+	SortByCreatedAt   = "created_at"
+	SortByProcessedAt = "processed_at"
+	SortByID          = "id"
+)
+
+// Направления сортировки сообщений.
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// MessageFilter хранит условия отбора сообщений. Пустые условия не применяются.
+type MessageFilter struct {
+	IDs      []uint64
+	Statuses []string
+	// Content это подстрока, которую должно содержать сообщение.
+	Content string
+	// CreatedFrom и CreatedTo задают полуинтервал [CreatedFrom, CreatedTo) даты создания.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// ProcessedFrom и ProcessedTo задают полуинтервал [ProcessedFrom, ProcessedTo) даты обработки.
+	ProcessedFrom *time.Time
+	ProcessedTo   *time.Time
+}
+
+// MessageSort задает порядок сообщений. Сообщения с одинаковым значением поля упорядочиваются по ID.
+type MessageSort struct {
+	Field string `json:"field"`
+	Order string `json:"order"`
+}
+
+// MessageQuery хранит параметры запроса страницы сообщений.
+type MessageQuery struct {
+	Filter MessageFilter
+	Sort   MessageSort
+	Page   PageRequest
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// MessageStats хранит статистику обработки сообщений.
+//
+// Processed, Failed и Cancelled это количество сообщений в соответствующих статусах,
+// Unprocessed это количество сообщений, обработка которых еще не завершена.
+type MessageStats struct {
+	Total       uint64
+	Processed   uint64
+	Failed      uint64
+	Cancelled   uint64
+	Unprocessed uint64
+	// Window это период, за который посчитана задержка обработки.
+	Window time.Duration
+	// Latency хранит перцентили задержки обработки сообщений, обработанных за период Window.
+	Latency LatencyStats
+}
+
+// LatencyStats хранит перцентили задержки обработки сообщений (processed_at - created_at).
+// Перцентили пусты, если за период не было обработано ни одного сообщения.
+type LatencyStats struct {
+	Count uint64
+	P50   *time.Duration
+	P90   *time.Duration
+	P95   *time.Duration
+	P99   *time.Duration
+}
@@ -0,0 +1,163 @@
+// Package kafka содержит общие для отправки и получения событий настройки клиента Kafka.
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+
+	"github.com/sedonn/message-service/internal/config"
+)
+
+// Режимы отправки событий.
+const (
+	// ProducerModeSync это режим, в котором каждая отправка ожидает подтверждения брокера через SyncProducer.
+	ProducerModeSync = "sync"
+	// ProducerModeAsync это режим, в котором события отправляются через AsyncProducer пакетами, накопленными
+	// по настройкам flush и linger, а результаты доставки приходят из каналов Successes и Errors.
+	ProducerModeAsync = "async"
+)
+
+// NewSaramaConfig создает конфигурацию клиента Kafka по конфигурации приложения.
+// Недопустимые значения настроек возвращаются ошибкой, чтобы приложение не запустилось с ними.
+func NewSaramaConfig(cfg *config.KafkaConfig) (*sarama.Config, error) {
+	saramaCfg := sarama.NewConfig()
+
+	version, err := sarama.ParseKafkaVersion(cfg.Version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka version: %w", err)
+	}
+
+	saramaCfg.ClientID = cfg.ClientID
+	saramaCfg.Version = version
+	saramaCfg.Net.DialTimeout = cfg.Net.DialTimeout
+	saramaCfg.Net.ReadTimeout = cfg.Net.ReadTimeout
+	saramaCfg.Net.WriteTimeout = cfg.Net.WriteTimeout
+
+	if err := applySASLConfig(saramaCfg, &cfg.SASL); err != nil {
+		return nil, err
+	}
+
+	if err := applyTLSConfig(saramaCfg, &cfg.TLS); err != nil {
+		return nil, err
+	}
+
+	if err := applyProducerConfig(saramaCfg, &cfg.Producer); err != nil {
+		return nil, err
+	}
+
+	if err := applyConsumerConfig(saramaCfg, &cfg.Consumer); err != nil {
+		return nil, err
+	}
+
+	if err := saramaCfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka config: %w", err)
+	}
+
+	return saramaCfg, nil
+}
+
+// applyProducerConfig применяет настройки отправки событий.
+func applyProducerConfig(saramaCfg *sarama.Config, cfg *config.ProducerConfig) error {
+	// Результаты доставки нужны в обоих режимах: SyncProducer ожидает их,
+	// а в асинхронном режиме по ним отслеживается доставка событий.
+	saramaCfg.Producer.Return.Successes = true
+	saramaCfg.Producer.Return.Errors = true
+
+	switch cfg.Mode {
+	case ProducerModeSync, ProducerModeAsync:
+	default:
+		return fmt.Errorf("unknown producer mode: %s", cfg.Mode)
+	}
+
+	switch cfg.RequiredAcks {
+	case "none":
+		saramaCfg.Producer.RequiredAcks = sarama.NoResponse
+	case "leader":
+		saramaCfg.Producer.RequiredAcks = sarama.WaitForLocal
+	case "all":
+		saramaCfg.Producer.RequiredAcks = sarama.WaitForAll
+	default:
+		return fmt.Errorf("unknown producer required acks: %s", cfg.RequiredAcks)
+	}
+
+	switch cfg.Compression {
+	case "none":
+		saramaCfg.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		saramaCfg.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		saramaCfg.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		saramaCfg.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		saramaCfg.Producer.Compression = sarama.CompressionZSTD
+	default:
+		return fmt.Errorf("unknown producer compression: %s", cfg.Compression)
+	}
+
+	switch cfg.Partitioner {
+	case "hash":
+		saramaCfg.Producer.Partitioner = sarama.NewHashPartitioner
+	case "random":
+		saramaCfg.Producer.Partitioner = sarama.NewRandomPartitioner
+	case "roundrobin":
+		saramaCfg.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	default:
+		return fmt.Errorf("unknown producer partitioner: %s", cfg.Partitioner)
+	}
+
+	if cfg.Idempotent {
+		if cfg.RequiredAcks != "all" {
+			return fmt.Errorf("idempotent producer requires required acks all, got %s", cfg.RequiredAcks)
+		}
+
+		saramaCfg.Producer.Idempotent = true
+		// Идемпотентная отправка сохраняет порядок только при одном запросе на подключение.
+		saramaCfg.Net.MaxOpenRequests = 1
+	}
+
+	saramaCfg.Producer.Flush.Messages = cfg.FlushMessages
+	saramaCfg.Producer.Flush.Bytes = cfg.FlushBytes
+	saramaCfg.Producer.Flush.Frequency = cfg.Linger
+	saramaCfg.Producer.MaxMessageBytes = cfg.MaxMessageBytes
+	saramaCfg.Producer.Timeout = cfg.Timeout
+	saramaCfg.Producer.Retry.Max = cfg.RetryMax
+
+	return nil
+}
+
+// applyConsumerConfig применяет настройки группы потребителей.
+func applyConsumerConfig(saramaCfg *sarama.Config, cfg *config.ConsumerConfig) error {
+	switch cfg.RebalanceStrategy {
+	case "range":
+		saramaCfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case "roundrobin":
+		saramaCfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case "sticky":
+		saramaCfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		return fmt.Errorf("unknown consumer rebalance strategy: %s", cfg.RebalanceStrategy)
+	}
+
+	switch cfg.InitialOffset {
+	case "newest":
+		saramaCfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	case "oldest":
+		saramaCfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	default:
+		return fmt.Errorf("unknown consumer initial offset: %s", cfg.InitialOffset)
+	}
+
+	if cfg.HeartbeatInterval >= cfg.SessionTimeout {
+		return fmt.Errorf("consumer heartbeat interval %s must be less than session timeout %s",
+			cfg.HeartbeatInterval, cfg.SessionTimeout)
+	}
+
+	saramaCfg.Consumer.Group.Session.Timeout = cfg.SessionTimeout
+	saramaCfg.Consumer.Group.Heartbeat.Interval = cfg.HeartbeatInterval
+	// Смещения фиксируются вручную только после успешной обработки события.
+	saramaCfg.Consumer.Offsets.AutoCommit.Enable = false
+
+	return nil
+}
@@ -6,21 +6,46 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/sedonn/message-service/internal/config"
 	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/event/kafka"
 	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+	"github.com/sedonn/message-service/internal/pkg/tracing"
 )
 
+// tracerName это имя трассировщика получения событий.
+const tracerName = "github.com/sedonn/message-service/internal/event/kafka/consumer"
+
+// errMalformedEvent возникает, если событие невозможно разобрать. Такие события не обрабатываются повторно.
+var errMalformedEvent = errors.New("malformed event")
+
 // MessageEventSubscriber описывает поведение объекта, который выполняет события связанные с сообщениямиЛ.
 type MessageEventSubscriber interface {
+	// OnMessageAcknowledged вызывается при получении сообщения обработчиком.
+	OnMessageAcknowledged(ctx context.Context, e events.AcknowledgeProcessingMessage) error
+
 	// OnMessageProcessed вызывается при завершении обработки сообщения.
-	OnMessageProcessed(ctx context.Context, e events.CompleteProcessingMessage)
+	OnMessageProcessed(ctx context.Context, e events.CompleteProcessingMessage) error
+}
+
+// DeadLetterProducer описывает поведение объекта, который отправляет необработанные события в топик недоставленных сообщений.
+type DeadLetterProducer interface {
+	// NotifyDeadLetter отправляет исходное событие вместе с причиной ошибки его обработки.
+	NotifyDeadLetter(ctx context.Context, msg *sarama.ConsumerMessage, reason error) error
 }
 
 // Consumer получает сообщения из kafka.
@@ -30,16 +55,29 @@ type Consumer struct {
 	client               sarama.ConsumerGroup
 	wg                   *sync.WaitGroup
 	ready                chan bool
+	readyOnce            *sync.Once
+	sessionActive        *atomic.Bool
 	messageEventConsumer MessageEventSubscriber
+	deadLetterProducer   DeadLetterProducer
+	metrics              *metrics.Consumer
 }
 
 var _ sarama.ConsumerGroupHandler = (*Consumer)(nil)
 
 // New создает нового Consumer.
-func New(log *slog.Logger, cfg *config.KafkaConfig, mec MessageEventSubscriber) (*Consumer, error) {
-	const group = "message-service"
+func New(
+	log *slog.Logger,
+	cfg *config.KafkaConfig,
+	mec MessageEventSubscriber,
+	dlp DeadLetterProducer,
+	m *metrics.Consumer,
+) (*Consumer, error) {
+	saramaCfg, err := kafka.NewSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := sarama.NewConsumerGroup(strings.Split(cfg.Brokers, ","), group, nil)
+	client, err := sarama.NewConsumerGroup(strings.Split(cfg.Brokers, ","), cfg.Consumer.Group, saramaCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize kafka consumer: %w", err)
 	}
@@ -50,7 +88,11 @@ func New(log *slog.Logger, cfg *config.KafkaConfig, mec MessageEventSubscriber)
 		client:               client,
 		wg:                   &sync.WaitGroup{},
 		ready:                make(chan bool),
+		readyOnce:            &sync.Once{},
+		sessionActive:        &atomic.Bool{},
 		messageEventConsumer: mec,
+		deadLetterProducer:   dlp,
+		metrics:              m,
 	}, nil
 }
 
@@ -63,7 +105,7 @@ func (c *Consumer) MustRun(ctx context.Context) {
 	go func() {
 		defer c.wg.Done()
 		for {
-			if err := c.client.Consume(ctx, []string{c.cfg.Topics.ProcessedMessages}, c); err != nil {
+			if err := c.client.Consume(ctx, c.topics(), c); err != nil {
 				if errors.Is(err, sarama.ErrClosedConsumerGroup) {
 					return
 				}
@@ -96,7 +138,9 @@ func (c *Consumer) Stop() {
 
 // Setup реализует метод ConsumerGroupHandler.Setup.
 func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
-	close(c.ready)
+	// Сессия создается заново после каждой ребалансировки и после ошибки обработки события.
+	c.readyOnce.Do(func() { close(c.ready) })
+	c.sessionActive.Store(true)
 	return nil
 }
 
@@ -116,11 +160,24 @@ func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 				slog.String("message_key", string(msg.Key)),
 				slog.String("topic", msg.Topic),
 			)
+			c.metrics.SetLag(msg.Topic, msg.Partition, claim.HighWaterMarkOffset()-msg.Offset-1)
 
-			switch msg.Topic {
-			case c.cfg.Topics.ProcessedMessages:
-				c.consumeMessageProcessedEvent(session.Context(), msg)
+			if err := c.handleMessage(session.Context(), msg); err != nil {
+				if session.Context().Err() != nil {
+					return nil
+				}
+
+				log.Error("failed to handle message, offset is not committed",
+					slog.String("topic", msg.Topic),
+					slog.Int64("offset", msg.Offset),
+					logger.StringError(err),
+				)
+
+				return err
 			}
+
+			session.MarkMessage(msg, "")
+			session.Commit()
 		case <-session.Context().Done():
 			return nil
 		}
@@ -129,21 +186,151 @@ func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 
 // Cleanup реализует метод sarama.ConsumerGroupHandler.
 func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	c.sessionActive.Store(false)
 	return nil
 }
 
-// consumeMessageProcessedEvent передает полученное событие о завершении обработки сообщения в подписчика.
-func (c *Consumer) consumeMessageProcessedEvent(ctx context.Context, msg *sarama.ConsumerMessage) {
-	const op = "consumer.consumeMessageProcessedEvent"
+// Check проверяет, что Consumer участвует в активной сессии группы потребителей.
+func (c *Consumer) Check(context.Context) error {
+	if !c.sessionActive.Load() {
+		return errors.New("consumer group session is not set up")
+	}
+
+	return nil
+}
+
+// handleMessage обрабатывает событие согласно политике повторов.
+// Событие, которое не удалось обработать, отправляется в топик недоставленных сообщений.
+//
+// Возвращает ошибку, если событие не было ни обработано, ни отправлено в топик недоставленных сообщений.
+// В этом случае смещение события не должно фиксироваться.
+func (c *Consumer) handleMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	const op = "consumer.handleMessage"
+	log := c.log.With(
+		slog.String("op", op),
+		slog.String("message_key", string(msg.Key)),
+		slog.String("topic", msg.Topic),
+		slog.Int64("offset", msg.Offset),
+	)
+
+	ctx, span := otel.Tracer(tracerName).Start(tracing.ExtractConsumerMessage(ctx, msg), msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.FormatInt(int64(msg.Partition), 10)),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+		),
+	)
+	defer span.End()
+
+	err := c.consumeWithRetries(ctx, msg)
+	switch {
+	case err == nil:
+		c.metrics.ObserveHandled(msg.Topic)
+		return nil
+	case errors.Is(err, models.ErrMessageAlreadyProcessed), errors.Is(err, models.ErrInvalidStatusTransition):
+		log.Warn("duplicate or stale event skipped", logger.StringError(err))
+		c.metrics.ObserveDuplicate(msg.Topic)
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	c.metrics.ObserveFailed(msg.Topic)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	log.Error("failed to handle message, sending to dead letter topic", logger.StringError(err))
+	if err := c.deadLetterProducer.NotifyDeadLetter(ctx, msg, err); err != nil {
+		return fmt.Errorf("failed to send message to dead letter topic: %w", err)
+	}
+
+	return nil
+}
+
+// consumeWithRetries передает событие в обработчик, повторяя попытки с увеличивающейся задержкой.
+func (c *Consumer) consumeWithRetries(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	const op = "consumer.consumeWithRetries"
 	log := c.log.With(slog.String("op", op), slog.String("message_key", string(msg.Key)))
 
+	backoff := c.cfg.Consumer.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.consume(ctx, msg)
+		if err == nil || !retryable(err) || attempt >= c.cfg.Consumer.RetryAttempts {
+			return err
+		}
+
+		log.Warn("failed to handle message, retrying", slog.Int("attempt", attempt), logger.StringError(err))
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// retryable проверяет, может ли повторная обработка события завершиться успешно.
+func retryable(err error) bool {
+	return !errors.Is(err, errMalformedEvent) &&
+		!errors.Is(err, models.ErrMessageAlreadyProcessed) &&
+		!errors.Is(err, models.ErrInvalidStatusTransition) &&
+		!errors.Is(err, models.ErrMessageNotFound)
+}
+
+// consume передает событие в обработчик, соответствующий топику.
+func (c *Consumer) consume(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	switch msg.Topic {
+	case c.cfg.Topics.AcknowledgedMessages:
+		return c.consumeMessageAcknowledgedEvent(ctx, msg)
+	case c.cfg.Topics.ProcessedMessages:
+		return c.consumeMessageProcessedEvent(ctx, msg)
+	}
+
+	return fmt.Errorf("%w: unexpected topic %s", errMalformedEvent, msg.Topic)
+}
+
+// topics возвращает топики, из которых получает события Consumer.
+func (c *Consumer) topics() []string {
+	return []string{c.cfg.Topics.AcknowledgedMessages, c.cfg.Topics.ProcessedMessages}
+}
+
+// consumeMessageAcknowledgedEvent передает полученное событие о получении сообщения обработчиком в подписчика.
+func (c *Consumer) consumeMessageAcknowledgedEvent(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	var e events.AcknowledgeProcessingMessage
+	if err := json.Unmarshal(msg.Value, &e); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal message value: %w", errMalformedEvent, err)
+	}
+
+	return c.messageEventConsumer.OnMessageAcknowledged(ctx, e)
+}
+
+// consumeMessageProcessedEvent передает полученное событие о завершении обработки сообщения в подписчика.
+//
+// Если обработчик не указал время завершения обработки, используется время получения события.
+// Если не указан статус обработки, обработка считается успешной.
+func (c *Consumer) consumeMessageProcessedEvent(ctx context.Context, msg *sarama.ConsumerMessage) error {
 	var e events.CompleteProcessingMessage
 	if err := json.Unmarshal(msg.Value, &e); err != nil {
-		log.Error("failed to unmarshal message value", logger.StringError(err))
-		return
+		return fmt.Errorf("%w: failed to unmarshal message value: %w", errMalformedEvent, err)
 	}
 
-	e.ProcessedAt = time.Now()
+	if e.ProcessedAt.IsZero() {
+		e.ProcessedAt = time.Now()
+	}
+
+	switch e.Status {
+	case "":
+		e.Status = events.ProcessingStatusSucceeded
+	case events.ProcessingStatusSucceeded, events.ProcessingStatusFailed:
+	default:
+		return fmt.Errorf("%w: unknown processing status %q", errMalformedEvent, e.Status)
+	}
 
-	c.messageEventConsumer.OnMessageProcessed(ctx, e)
+	return c.messageEventConsumer.OnMessageProcessed(ctx, e)
 }
@@ -0,0 +1,246 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+)
+
+const (
+	testAcknowledgedTopic = "acknowledged-messages"
+	testProcessedTopic    = "processed-messages"
+)
+
+// fakeSession это сессия группы потребителей, которая запоминает отмеченные и зафиксированные смещения.
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx     context.Context
+	marked  []int64
+	commits int
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) Commit() { s.commits++ }
+
+// fakeClaim это партиция, события которой заранее записаны в канал.
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim(msgs ...*sarama.ConsumerMessage) *fakeClaim {
+	c := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		c.messages <- msg
+	}
+	close(c.messages)
+
+	return c
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func (c *fakeClaim) HighWaterMarkOffset() int64 { return int64(cap(c.messages)) }
+
+// fakeSubscriber это MessageEventSubscriber, который возвращает ошибки из errs по очереди.
+// После исчерпания errs события обрабатываются успешно.
+type fakeSubscriber struct {
+	errs  []error
+	calls int
+}
+
+func (s *fakeSubscriber) next() error {
+	s.calls++
+	if len(s.errs) == 0 {
+		return nil
+	}
+
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+
+	return err
+}
+
+func (s *fakeSubscriber) OnMessageAcknowledged(context.Context, events.AcknowledgeProcessingMessage) error {
+	return s.next()
+}
+
+func (s *fakeSubscriber) OnMessageProcessed(context.Context, events.CompleteProcessingMessage) error {
+	return s.next()
+}
+
+// fakeDeadLetterProducer это DeadLetterProducer, который запоминает смещения отправленных событий.
+type fakeDeadLetterProducer struct {
+	err     error
+	offsets []int64
+}
+
+func (p *fakeDeadLetterProducer) NotifyDeadLetter(_ context.Context, msg *sarama.ConsumerMessage, _ error) error {
+	p.offsets = append(p.offsets, msg.Offset)
+	return p.err
+}
+
+func newTestConsumer(mec MessageEventSubscriber, dlp DeadLetterProducer) *Consumer {
+	return &Consumer{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg: &config.KafkaConfig{
+			Topics: config.KafkaTopics{
+				AcknowledgedMessages: testAcknowledgedTopic,
+				ProcessedMessages:    testProcessedTopic,
+			},
+			Consumer: config.ConsumerConfig{
+				RetryAttempts: 3,
+				RetryBackoff:  time.Millisecond,
+			},
+		},
+		wg:                   &sync.WaitGroup{},
+		ready:                make(chan bool),
+		readyOnce:            &sync.Once{},
+		sessionActive:        &atomic.Bool{},
+		messageEventConsumer: mec,
+		deadLetterProducer:   dlp,
+		metrics:              metrics.NewConsumer(prometheus.NewRegistry()),
+	}
+}
+
+func newTestMessage(topic string, offset int64, value string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{Topic: topic, Offset: offset, Value: []byte(value)}
+}
+
+func TestConsumeClaim(t *testing.T) {
+	errDB := errors.New("database is unavailable")
+
+	tests := []struct {
+		name           string
+		msg            *sarama.ConsumerMessage
+		errs           []error
+		dlqErr         error
+		wantErr        bool
+		wantCalls      int
+		wantDeadLetter bool
+		wantCommitted  bool
+	}{
+		{
+			name:          "handled",
+			msg:           newTestMessage(testProcessedTopic, 0, `{"id":1}`),
+			wantCalls:     1,
+			wantCommitted: true,
+		},
+		{
+			name:          "handled after retry",
+			msg:           newTestMessage(testAcknowledgedTopic, 0, `{"id":1}`),
+			errs:          []error{errDB},
+			wantCalls:     2,
+			wantCommitted: true,
+		},
+		{
+			name:           "retries exhausted",
+			msg:            newTestMessage(testProcessedTopic, 0, `{"id":1}`),
+			errs:           []error{errDB, errDB, errDB},
+			wantCalls:      3,
+			wantDeadLetter: true,
+			wantCommitted:  true,
+		},
+		{
+			name:           "non-retryable error",
+			msg:            newTestMessage(testProcessedTopic, 0, `{"id":1}`),
+			errs:           []error{models.ErrMessageNotFound},
+			wantCalls:      1,
+			wantDeadLetter: true,
+			wantCommitted:  true,
+		},
+		{
+			name:           "malformed event",
+			msg:            newTestMessage(testProcessedTopic, 0, `{`),
+			wantCalls:      0,
+			wantDeadLetter: true,
+			wantCommitted:  true,
+		},
+		{
+			name:           "unknown processing status",
+			msg:            newTestMessage(testProcessedTopic, 0, `{"id":1,"status":"unknown"}`),
+			wantCalls:      0,
+			wantDeadLetter: true,
+			wantCommitted:  true,
+		},
+		{
+			name:          "duplicate event",
+			msg:           newTestMessage(testProcessedTopic, 0, `{"id":1}`),
+			errs:          []error{models.ErrMessageAlreadyProcessed},
+			wantCalls:     1,
+			wantCommitted: true,
+		},
+		{
+			name:           "dead letter failed",
+			msg:            newTestMessage(testProcessedTopic, 0, `{"id":1}`),
+			errs:           []error{models.ErrMessageNotFound},
+			dlqErr:         errors.New("broker is unavailable"),
+			wantErr:        true,
+			wantCalls:      1,
+			wantDeadLetter: true,
+			wantCommitted:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mec := &fakeSubscriber{errs: tt.errs}
+			dlp := &fakeDeadLetterProducer{err: tt.dlqErr}
+			session := &fakeSession{ctx: context.Background()}
+
+			err := newTestConsumer(mec, dlp).ConsumeClaim(session, newFakeClaim(tt.msg))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConsumeClaim() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if mec.calls != tt.wantCalls {
+				t.Errorf("subscriber calls = %d, want %d", mec.calls, tt.wantCalls)
+			}
+			if got := len(dlp.offsets) > 0; got != tt.wantDeadLetter {
+				t.Errorf("sent to dead letter topic = %v, want %v", got, tt.wantDeadLetter)
+			}
+			if got := len(session.marked) > 0 && session.commits > 0; got != tt.wantCommitted {
+				t.Errorf("offset committed = %v, want %v", got, tt.wantCommitted)
+			}
+		})
+	}
+}
+
+func TestConsumeClaimStopsAtFailedMessage(t *testing.T) {
+	mec := &fakeSubscriber{errs: []error{models.ErrMessageNotFound}}
+	dlp := &fakeDeadLetterProducer{err: errors.New("broker is unavailable")}
+	session := &fakeSession{ctx: context.Background()}
+	claim := newFakeClaim(
+		newTestMessage(testProcessedTopic, 0, `{"id":1}`),
+		newTestMessage(testProcessedTopic, 1, `{"id":2}`),
+	)
+
+	if err := newTestConsumer(mec, dlp).ConsumeClaim(session, claim); err == nil {
+		t.Fatal("ConsumeClaim() error = nil, want error")
+	}
+
+	if mec.calls != 1 {
+		t.Errorf("subscriber calls = %d, want 1", mec.calls)
+	}
+	if len(session.marked) != 0 || session.commits != 0 {
+		t.Errorf("marked offsets = %v, commits = %d, want none", session.marked, session.commits)
+	}
+}
@@ -0,0 +1,93 @@
+package producer
+
+import (
+	"context"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// delivery отслеживает доставку сообщения, отправленного через AsyncProducer.
+type delivery struct {
+	span  trace.Span
+	start time.Time
+	done  chan error
+}
+
+// enqueue передает сообщение в AsyncProducer и возвращает объект отслеживания его доставки.
+// Возвращает ошибку, если Producer остановлен или контекст отменен до передачи сообщения.
+func (p *Producer) enqueue(ctx context.Context, msg *sarama.ProducerMessage, batchSize int) (*delivery, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return nil, errProducerClosed
+	}
+
+	_, span := p.startSpan(ctx, msg, batchSize)
+
+	d := &delivery{
+		span:  span,
+		start: time.Now(),
+		done:  make(chan error, 1),
+	}
+
+	msg.Metadata = d
+	select {
+	case p.ap.Input() <- msg:
+		return d, nil
+	case <-ctx.Done():
+		endSpan(span, ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+// wait ожидает результата доставки сообщения или отмены контекста.
+// Результат доставки записывается в буферизованный канал, поэтому прерванное ожидание не блокирует его запись.
+func (d *delivery) wait(ctx context.Context) error {
+	select {
+	case err := <-d.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackDeliveries получает результаты доставки из каналов AsyncProducer, учитывает метрики отправки
+// и передает результат ожидающему отправителю. Завершается после закрытия AsyncProducer.
+func (p *Producer) trackDeliveries() {
+	defer p.wg.Done()
+
+	successes, errs := p.ap.Successes(), p.ap.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case msg, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+
+			p.completeDelivery(msg, nil)
+		case pe, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+
+			p.completeDelivery(pe.Msg, pe.Err)
+		}
+	}
+}
+
+// completeDelivery фиксирует результат доставки сообщения.
+func (p *Producer) completeDelivery(msg *sarama.ProducerMessage, err error) {
+	d, ok := msg.Metadata.(*delivery)
+	if !ok {
+		return
+	}
+
+	p.metrics.ObserveSend(msg.Topic, time.Since(d.start), err)
+	endSpan(d.span, err)
+	d.done <- err
+}
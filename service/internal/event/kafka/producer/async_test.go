@@ -0,0 +1,160 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+)
+
+// fakeAsyncProducer это AsyncProducer без брокера. Сообщения из Input читает сам тест.
+type fakeAsyncProducer struct {
+	sarama.AsyncProducer
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+}
+
+func newFakeAsyncProducer() *fakeAsyncProducer {
+	return &fakeAsyncProducer{
+		input:     make(chan *sarama.ProducerMessage),
+		successes: make(chan *sarama.ProducerMessage, 1),
+		errors:    make(chan *sarama.ProducerError, 1),
+	}
+}
+
+func (p *fakeAsyncProducer) Input() chan<- *sarama.ProducerMessage { return p.input }
+
+func (p *fakeAsyncProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+
+func (p *fakeAsyncProducer) Errors() <-chan *sarama.ProducerError { return p.errors }
+
+func (p *fakeAsyncProducer) AsyncClose() {
+	close(p.successes)
+	close(p.errors)
+}
+
+// fakeClient это Client, который закрывается без подключения к брокерам.
+type fakeClient struct {
+	sarama.Client
+}
+
+func (fakeClient) Close() error { return nil }
+
+func newTestAsyncProducer(ap sarama.AsyncProducer) *Producer {
+	p := &Producer{
+		cfg:     &config.KafkaConfig{},
+		client:  fakeClient{},
+		ap:      ap,
+		wg:      &sync.WaitGroup{},
+		metrics: metrics.NewProducer(prometheus.NewRegistry()),
+		mu:      &sync.RWMutex{},
+	}
+
+	p.wg.Add(1)
+	go p.trackDeliveries()
+
+	return p
+}
+
+func TestSendAsync(t *testing.T) {
+	errBroker := errors.New("broker is unavailable")
+
+	tests := []struct {
+		name    string
+		deliver func(ap *fakeAsyncProducer, msg *sarama.ProducerMessage)
+		wantErr error
+	}{
+		{
+			name:    "delivered",
+			deliver: func(ap *fakeAsyncProducer, msg *sarama.ProducerMessage) { ap.successes <- msg },
+		},
+		{
+			name: "not delivered",
+			deliver: func(ap *fakeAsyncProducer, msg *sarama.ProducerMessage) {
+				ap.errors <- &sarama.ProducerError{Msg: msg, Err: errBroker}
+			},
+			wantErr: errBroker,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ap := newFakeAsyncProducer()
+			p := newTestAsyncProducer(ap)
+			t.Cleanup(func() { _ = p.Stop() })
+
+			go func() { tt.deliver(ap, <-ap.input) }()
+
+			if err := p.send(context.Background(), newProducerMessage("processing-messages", "key", []byte("{}"))); !errors.Is(err, tt.wantErr) {
+				t.Errorf("send() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendAsyncContextDone(t *testing.T) {
+	tests := []struct {
+		name string
+		// accept определяет, принимает ли AsyncProducer сообщение до отмены контекста.
+		accept bool
+	}{
+		{name: "input is full", accept: false},
+		{name: "delivery is pending", accept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ap := newFakeAsyncProducer()
+			p := newTestAsyncProducer(ap)
+
+			accepted := make(chan *sarama.ProducerMessage, 1)
+			if tt.accept {
+				go func() { accepted <- <-ap.input }()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			if err := p.send(ctx, newProducerMessage("processing-messages", "key", []byte("{}"))); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("send() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			// Результат доставки, полученный после отмены ожидания, не блокирует отслеживание доставок.
+			if tt.accept {
+				ap.successes <- <-accepted
+			}
+
+			if err := p.Stop(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSendAsyncAfterStop(t *testing.T) {
+	p := newTestAsyncProducer(newFakeAsyncProducer())
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := p.send(context.Background(), newProducerMessage("processing-messages", "key", []byte("{}")))
+	if !errors.Is(err, errProducerClosed) {
+		t.Errorf("send() error = %v, want %v", err, errProducerClosed)
+	}
+
+	errs := p.sendBatch(
+		[]context.Context{context.Background()},
+		[]*sarama.ProducerMessage{newProducerMessage("processing-messages", "key", []byte("{}"))},
+	)
+	if !errors.Is(errs[0], errProducerClosed) {
+		t.Errorf("sendBatch() errors = %v, want %v", errs, errProducerClosed)
+	}
+}
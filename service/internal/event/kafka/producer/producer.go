@@ -1,49 +1,181 @@
 package producer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/sedonn/message-service/internal/config"
 	"github.com/sedonn/message-service/internal/domain/events"
-	"github.com/sedonn/message-service/internal/services/message"
+	"github.com/sedonn/message-service/internal/event/kafka"
+	"github.com/sedonn/message-service/internal/event/kafka/consumer"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+	"github.com/sedonn/message-service/internal/pkg/tracing"
 )
 
+// tracerName это имя трассировщика отправки событий.
+const tracerName = "github.com/sedonn/message-service/internal/event/kafka/producer"
+
+// Заголовки, с которыми событие отправляется в топик недоставленных сообщений.
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderFailureReason     = "x-failure-reason"
+)
+
+// errProducerClosed возникает при отправке сообщения после остановки Producer.
+var errProducerClosed = errors.New("kafka producer is closed")
+
 // Producer отправляет сообщения в Kafka.
+//
+// В синхронном режиме сообщения отправляются через SyncProducer. В асинхронном режиме сообщения
+// передаются в AsyncProducer, а отправитель ожидает результат доставки из каналов Successes и Errors,
+// поэтому сообщения одновременных отправителей объединяются в общие пакеты.
 type Producer struct {
-	cfg *config.KafkaConfig
-	sp  sarama.SyncProducer
+	cfg     *config.KafkaConfig
+	client  sarama.Client
+	sp      sarama.SyncProducer
+	ap      sarama.AsyncProducer
+	wg      *sync.WaitGroup
+	metrics *metrics.Producer
+
+	// mu защищает closed. Сообщения передаются в AsyncProducer под блокировкой на чтение,
+	// поэтому после закрытия AsyncProducer в него больше ничего не передается.
+	mu     *sync.RWMutex
+	closed bool
 }
 
-var _ message.MessageEventProducer = (*Producer)(nil)
+var _ consumer.DeadLetterProducer = (*Producer)(nil)
 
 // New создает нового Producer.
-func New(cfg *config.KafkaConfig) (*Producer, error) {
-	sp, err := sarama.NewSyncProducer(strings.Split(cfg.Brokers, ","), nil)
+func New(cfg *config.KafkaConfig, m *metrics.Producer) (*Producer, error) {
+	saramaCfg, err := kafka.NewSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sarama.NewClient(strings.Split(cfg.Brokers, ","), saramaCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize kafka client: %w", err)
+	}
+
+	p := &Producer{
+		cfg:     cfg,
+		client:  client,
+		wg:      &sync.WaitGroup{},
+		metrics: m,
+		mu:      &sync.RWMutex{},
+	}
+
+	if cfg.Producer.Mode == kafka.ProducerModeAsync {
+		p.ap, err = sarama.NewAsyncProducerFromClient(client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize kafka producer: %w", err)
+		}
+
+		p.wg.Add(1)
+		go p.trackDeliveries()
+
+		return p, nil
+	}
+
+	p.sp, err = sarama.NewSyncProducerFromClient(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize kafka producer: %w", err)
 	}
 
-	return &Producer{
-		cfg: cfg,
-		sp:  sp,
-	}, nil
+	return p, nil
 }
 
 // Stop закрывает подключение Producer.
-func (p *Producer) Stop() error { return p.sp.Close() }
+// В асинхронном режиме ожидает результатов доставки всех переданных сообщений.
+// Отправка сообщений после остановки завершается ошибкой.
+func (p *Producer) Stop() error {
+	if p.ap != nil {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+
+		p.ap.AsyncClose()
+		p.wg.Wait()
+	} else if err := p.sp.Close(); err != nil {
+		return err
+	}
+
+	return p.client.Close()
+}
+
+// Check проверяет доступность брокеров Kafka, запрашивая метаданные топиков Producer.
+func (p *Producer) Check(ctx context.Context) error {
+	if p.client.Closed() {
+		return errors.New("kafka client is closed")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.client.RefreshMetadata(p.cfg.Topics.ProcessingMessages, p.cfg.Topics.DeadLetter)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
 // NotifyStartProcessingMessage создает событие старта обработки сообщения.
-func (p *Producer) NotifyStartProcessingMessage(e events.StartProcessingMessage) error {
-	return p.sendMessage(p.cfg.Topics.ProcessingMessages, e)
+func (p *Producer) NotifyStartProcessingMessage(ctx context.Context, e events.StartProcessingMessage) error {
+	return p.sendMessage(ctx, p.cfg.Topics.ProcessingMessages, e)
+}
+
+// NotifyDeadLetter отправляет исходное событие вместе с причиной ошибки его обработки
+// в топик недоставленных сообщений.
+func (p *Producer) NotifyDeadLetter(ctx context.Context, msg *sarama.ConsumerMessage, reason error) error {
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+4)
+	for _, h := range msg.Headers {
+		if h != nil {
+			headers = append(headers, *h)
+		}
+	}
+
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(HeaderOriginalTopic), Value: []byte(msg.Topic)},
+		sarama.RecordHeader{Key: []byte(HeaderOriginalPartition), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+		sarama.RecordHeader{Key: []byte(HeaderOriginalOffset), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		sarama.RecordHeader{Key: []byte(HeaderFailureReason), Value: []byte(reason.Error())},
+	)
+
+	dlMsg := &sarama.ProducerMessage{
+		Topic:   p.cfg.Topics.DeadLetter,
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+
+	if err := p.send(ctx, dlMsg); err != nil {
+		return fmt.Errorf("failed to produce dead letter message: %w", err)
+	}
+
+	return nil
 }
 
 // sendMessage обертка для отправки событий в Kafka.
-func (p *Producer) sendMessage(topic string, payload any) error {
+func (p *Producer) sendMessage(ctx context.Context, topic string, payload any) error {
 	requestID := uuid.New().String()
 
 	pBytes, err := json.Marshal(payload)
@@ -51,15 +183,135 @@ func (p *Producer) sendMessage(topic string, payload any) error {
 		return fmt.Errorf("failed to marshal event payload: %w", err)
 	}
 
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Key:   sarama.StringEncoder(requestID),
-		Value: sarama.ByteEncoder(pBytes),
-	}
+	return p.sendRaw(ctx, topic, requestID, pBytes)
+}
 
-	if _, _, err := p.sp.SendMessage(msg); err != nil {
+// sendRaw отправляет в Kafka уже сериализованное событие.
+func (p *Producer) sendRaw(ctx context.Context, topic, key string, payload []byte) error {
+	if err := p.send(ctx, newProducerMessage(topic, key, payload)); err != nil {
 		return fmt.Errorf("failed to produce message: %w", err)
 	}
 
 	return nil
 }
+
+// send отправляет сообщение в Kafka и ожидает результата его доставки, учитывает метрики отправки
+// и передает контекст трассировки в заголовках сообщения.
+//
+// В асинхронном режиме ожидание прерывается при отмене контекста. Сообщение, уже переданное
+// в AsyncProducer, при этом может быть доставлено.
+func (p *Producer) send(ctx context.Context, msg *sarama.ProducerMessage) error {
+	if p.ap != nil {
+		d, err := p.enqueue(ctx, msg, 1)
+		if err != nil {
+			return err
+		}
+
+		return d.wait(ctx)
+	}
+
+	_, span := p.startSpan(ctx, msg, 1)
+
+	start := time.Now()
+	_, _, err := p.sp.SendMessage(msg)
+	p.metrics.ObserveSend(msg.Topic, time.Since(start), err)
+	endSpan(span, err)
+
+	return err
+}
+
+// sendBatch отправляет пакет сообщений в Kafka и ожидает результатов их доставки.
+// В синхронном режиме пакет отправляется одним вызовом SendMessages.
+// Контекст трассировки каждого сообщения берется из ctxs с тем же индексом.
+//
+// Возвращает ошибки отправки сообщений в порядке их передачи. Ошибка отправленного сообщения равна nil.
+func (p *Producer) sendBatch(ctxs []context.Context, msgs []*sarama.ProducerMessage) []error {
+	errs := make([]error, len(msgs))
+
+	if p.ap != nil {
+		deliveries := make([]*delivery, len(msgs))
+		for i, msg := range msgs {
+			deliveries[i], errs[i] = p.enqueue(ctxs[i], msg, len(msgs))
+		}
+
+		for i, d := range deliveries {
+			if d != nil {
+				errs[i] = d.wait(ctxs[i])
+			}
+		}
+
+		return errs
+	}
+
+	spans := make([]trace.Span, len(msgs))
+	index := make(map[*sarama.ProducerMessage]int, len(msgs))
+	for i, msg := range msgs {
+		_, spans[i] = p.startSpan(ctxs[i], msg, len(msgs))
+		index[msg] = i
+	}
+
+	start := time.Now()
+	err := p.sp.SendMessages(msgs)
+	d := time.Since(start)
+
+	var producerErrs sarama.ProducerErrors
+	switch {
+	case errors.As(err, &producerErrs):
+		for _, pe := range producerErrs {
+			if i, ok := index[pe.Msg]; ok {
+				errs[i] = pe.Err
+			}
+		}
+	case err != nil:
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+
+	for i, msg := range msgs {
+		p.metrics.ObserveSend(msg.Topic, d, errs[i])
+		endSpan(spans[i], errs[i])
+	}
+
+	return errs
+}
+
+// startSpan начинает спан отправки сообщения и передает его контекст в заголовках сообщения.
+func (p *Producer) startSpan(ctx context.Context, msg *sarama.ProducerMessage, batchSize int) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemKafka,
+		semconv.MessagingOperationTypePublish,
+		semconv.MessagingDestinationName(msg.Topic),
+	}
+	if batchSize > 1 {
+		attrs = append(attrs, semconv.MessagingBatchMessageCount(batchSize))
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	)
+
+	tracing.InjectProducerMessage(ctx, msg)
+
+	return ctx, span
+}
+
+// endSpan завершает спан отправки сообщения с учетом ее результата.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// newProducerMessage создает сообщение Kafka с уже сериализованным событием.
+func newProducerMessage(topic, key string, payload []byte) *sarama.ProducerMessage {
+	return &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(payload),
+	}
+}
@@ -0,0 +1,92 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+	"github.com/sedonn/message-service/internal/pkg/tracing"
+)
+
+func TestSendTracing(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.NewWithOptions(sdktrace.WithSyncer(exp))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	errBroker := errors.New("broker is unavailable")
+	var sent []*sarama.ProducerMessage
+	sp := &fakeSyncProducer{send: func(msg *sarama.ProducerMessage) error {
+		sent = append(sent, msg)
+		if len(sent) > 1 {
+			return errBroker
+		}
+
+		return nil
+	}}
+
+	p := &Producer{
+		cfg:     &config.KafkaConfig{},
+		sp:      sp,
+		wg:      &sync.WaitGroup{},
+		metrics: metrics.NewProducer(prometheus.NewRegistry()),
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	if err := p.sendRaw(ctx, "processing-messages", "key", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.sendRaw(ctx, "processing-messages", "key", []byte("{}")); !errors.Is(err, errBroker) {
+		t.Fatalf("sendRaw() error = %v, want %v", err, errBroker)
+	}
+	parent.End()
+
+	spans := exp.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("spans = %d, want 3", len(spans))
+	}
+
+	for i, s := range spans[:2] {
+		if s.Name != "processing-messages publish" || s.SpanKind != trace.SpanKindProducer {
+			t.Errorf("span %d = %s (%s), want producer span", i, s.Name, s.SpanKind)
+		}
+		if s.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %d parent = %s, want %s", i, s.Parent.SpanID(), parent.SpanContext().SpanID())
+		}
+
+		var hasDestination bool
+		for _, attr := range s.Attributes {
+			if attr == semconv.MessagingDestinationName("processing-messages") {
+				hasDestination = true
+			}
+		}
+		if !hasDestination {
+			t.Errorf("span %d has no destination attribute", i)
+		}
+
+		// В заголовках сообщения передается контекст спана отправки, а не родительского спана.
+		ctx := tracing.ExtractMap(context.Background(), map[string]string{
+			string(sent[i].Headers[0].Key): string(sent[i].Headers[0].Value),
+		})
+		if got := trace.SpanContextFromContext(ctx).SpanID(); got != s.SpanContext.SpanID() {
+			t.Errorf("message %d span = %s, want %s", i, got, s.SpanContext.SpanID())
+		}
+	}
+
+	if spans[0].Status.Code != codes.Unset {
+		t.Errorf("sent message span status = %s, want unset", spans[0].Status.Code)
+	}
+	if spans[1].Status.Code != codes.Error {
+		t.Errorf("failed message span status = %s, want error", spans[1].Status.Code)
+	}
+}
@@ -0,0 +1,252 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/pkg/tracing"
+)
+
+// OutboxProvider описывает поведение объекта, который обеспечивает доступ к событиям outbox.
+type OutboxProvider interface {
+	// ClaimOutboxEvents захватывает неотправленные события в порядке их создания на время lease.
+	// Захваченное событие не возвращается другим вызовам до истечения lease.
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+
+	// MarkOutboxEventsSent отмечает события как отправленные.
+	MarkOutboxEventsSent(ctx context.Context, ids []uint64) error
+
+	// MarkOutboxEventFailed фиксирует неудачную попытку отправки события и освобождает его.
+	// Если dead установлен, событие больше не отправляется, а созданное сообщение, обработку которого
+	// оно должно было начать, завершается с ошибкой в той же транзакции.
+	MarkOutboxEventFailed(ctx context.Context, id uint64, reason string, dead bool) error
+}
+
+// errUnknownEventType возникает при отправке события неизвестного типа. Такие события не отправляются повторно.
+var errUnknownEventType = errors.New("unknown outbox event type")
+
+// MessageEventSubscriber описывает поведение объекта, который выполняет события, связанные с отправкой сообщений.
+type MessageEventSubscriber interface {
+	// OnMessageQueued вызывается после отправки события старта обработки сообщения.
+	OnMessageQueued(ctx context.Context, id uint64) error
+}
+
+// Relay периодически отправляет в Kafka события, сохраненные в outbox.
+type Relay struct {
+	log            *slog.Logger
+	cfg            *config.KafkaConfig
+	producer       *Producer
+	outboxProvider OutboxProvider
+	subscriber     MessageEventSubscriber
+	wg             *sync.WaitGroup
+}
+
+// NewRelay создает новый Relay.
+func NewRelay(log *slog.Logger, cfg *config.KafkaConfig, p *Producer, op OutboxProvider, mes MessageEventSubscriber) *Relay {
+	return &Relay{
+		log:            log,
+		cfg:            cfg,
+		producer:       p,
+		outboxProvider: op,
+		subscriber:     mes,
+		wg:             &sync.WaitGroup{},
+	}
+}
+
+// Run запускает фоновую отправку событий. Отправка прекращается при отмене контекста.
+func (r *Relay) Run(ctx context.Context) {
+	const op = "relay.Run"
+	log := r.log.With(slog.String("op", op))
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.cfg.Outbox.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.relayPending(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Info("outbox relay start working")
+}
+
+// Stop ожидает завершения текущей отправки событий.
+func (r *Relay) Stop() {
+	const op = "relay.Stop"
+	log := r.log.With(slog.String("op", op))
+
+	log.Info("stopping outbox relay")
+	r.wg.Wait()
+	log.Info("outbox relay stopped")
+}
+
+// relayPending захватывает очередной пакет неотправленных событий и отправляет его одним пакетом Kafka.
+//
+// События, которые не удалось отправить, будут отправлены повторно на следующей итерации,
+// поэтому порядок отправки событий не гарантируется. События неизвестного типа и события,
+// которые не удалось отправить за максимальное количество попыток, больше не отправляются,
+// а их сообщения завершаются с ошибкой.
+func (r *Relay) relayPending(ctx context.Context) {
+	const op = "relay.relayPending"
+	log := r.log.With(slog.String("op", op))
+
+	outboxEvents, err := r.outboxProvider.ClaimOutboxEvents(ctx, r.cfg.Outbox.BatchSize, r.cfg.Outbox.LockTimeout)
+	if err != nil {
+		log.Error("failed to claim pending outbox events", logger.StringError(err))
+		return
+	}
+
+	if len(outboxEvents) == 0 {
+		return
+	}
+
+	errs := r.sendWithRetries(ctx, outboxEvents)
+
+	sent := make([]models.OutboxEvent, 0, len(outboxEvents))
+	for i, e := range outboxEvents {
+		if errs[i] == nil {
+			sent = append(sent, e)
+			continue
+		}
+
+		log := log.With(
+			slog.Uint64("outbox_event_id", e.ID),
+			slog.String("type", e.Type),
+			slog.Int("attempt", e.Attempts+1),
+		)
+		log.Error("failed to send outbox event", logger.StringError(errs[i]))
+
+		dead := errors.Is(errs[i], errUnknownEventType) || e.Attempts+1 >= r.cfg.Outbox.MaxAttempts
+		if err := r.outboxProvider.MarkOutboxEventFailed(ctx, e.ID, errs[i].Error(), dead); err != nil {
+			log.Error("failed to mark outbox event as failed", logger.StringError(err))
+			continue
+		}
+
+		if dead {
+			log.Error("outbox event will not be sent again")
+		}
+	}
+
+	if len(sent) == 0 {
+		return
+	}
+
+	ids := make([]uint64, len(sent))
+	for i, e := range sent {
+		ids[i] = e.ID
+	}
+
+	if err := r.outboxProvider.MarkOutboxEventsSent(ctx, ids); err != nil {
+		log.Error("failed to mark outbox events as sent", logger.StringError(err))
+		return
+	}
+
+	for _, e := range sent {
+		if err := r.notifySent(ctx, e); err != nil {
+			log.Warn("failed to notify about sent outbox event",
+				slog.Uint64("outbox_event_id", e.ID),
+				logger.StringError(err),
+			)
+		}
+	}
+
+	log.Debug("outbox events sent", slog.Int("events_count", len(sent)))
+}
+
+// sendWithRetries отправляет события одним пакетом, повторяя отправку неотправленных событий
+// с увеличивающейся задержкой. Возвращает ошибки отправки событий в порядке их передачи.
+func (r *Relay) sendWithRetries(ctx context.Context, outboxEvents []models.OutboxEvent) []error {
+	errs := make([]error, len(outboxEvents))
+	ctxs := make([]context.Context, len(outboxEvents))
+	topics := make([]string, len(outboxEvents))
+
+	pending := make([]int, 0, len(outboxEvents))
+	for i, e := range outboxEvents {
+		topic, err := r.topic(e.Type)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		ctxs[i] = tracing.ExtractMap(ctx, e.Headers)
+		topics[i] = topic
+		pending = append(pending, i)
+	}
+
+	backoff := r.cfg.Outbox.RetryBackoff
+	for attempt := 1; len(pending) > 0; attempt++ {
+		batchCtxs := make([]context.Context, len(pending))
+		batchMsgs := make([]*sarama.ProducerMessage, len(pending))
+		for j, i := range pending {
+			// Сообщение создается заново на каждую попытку, так как sarama изменяет отправленные сообщения.
+			batchCtxs[j] = ctxs[i]
+			batchMsgs[j] = newProducerMessage(topics[i], outboxEvents[i].Key, outboxEvents[i].Payload)
+		}
+
+		failed := make([]int, 0, len(pending))
+		for j, err := range r.producer.sendBatch(batchCtxs, batchMsgs) {
+			errs[pending[j]] = err
+			if err != nil {
+				failed = append(failed, pending[j])
+			}
+		}
+
+		pending = failed
+		if len(pending) == 0 || attempt >= r.cfg.Outbox.RetryAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return errs
+		}
+	}
+
+	return errs
+}
+
+// notifySent сообщает подписчику об отправке события.
+func (r *Relay) notifySent(ctx context.Context, e models.OutboxEvent) error {
+	switch e.Type {
+	case events.TypeStartProcessingMessage:
+		var payload events.StartProcessingMessage
+		if err := json.Unmarshal(e.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal event payload: %w", err)
+		}
+
+		return r.subscriber.OnMessageQueued(ctx, payload.ID)
+	}
+
+	return nil
+}
+
+// topic возвращает топик Kafka для указанного типа события.
+func (r *Relay) topic(eventType string) (string, error) {
+	switch eventType {
+	case events.TypeStartProcessingMessage:
+		return r.cfg.Topics.ProcessingMessages, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", errUnknownEventType, eventType)
+}
@@ -0,0 +1,255 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+)
+
+// fakeSyncProducer это SyncProducer, который отправляет сообщения без брокера.
+// Результат отправки каждого сообщения определяет функция send.
+type fakeSyncProducer struct {
+	sarama.SyncProducer
+	send  func(msg *sarama.ProducerMessage) error
+	calls int
+}
+
+func (p *fakeSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return 0, 0, p.send(msg)
+}
+
+func (p *fakeSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.calls++
+
+	var errs sarama.ProducerErrors
+	for _, msg := range msgs {
+		if err := p.send(msg); err != nil {
+			errs = append(errs, &sarama.ProducerError{Msg: msg, Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// failedEvent это зафиксированная неудачная отправка события.
+type failedEvent struct {
+	id   uint64
+	dead bool
+}
+
+// fakeOutboxProvider это OutboxProvider, который хранит события и статусы сообщений в памяти.
+type fakeOutboxProvider struct {
+	events   []models.OutboxEvent
+	messages map[uint64]string
+	lease    time.Duration
+	sent     []uint64
+	failed   []failedEvent
+}
+
+func (p *fakeOutboxProvider) ClaimOutboxEvents(_ context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	p.lease = lease
+	return p.events[:min(limit, len(p.events))], nil
+}
+
+func (p *fakeOutboxProvider) MarkOutboxEventsSent(_ context.Context, ids []uint64) error {
+	p.sent = append(p.sent, ids...)
+	return nil
+}
+
+func (p *fakeOutboxProvider) MarkOutboxEventFailed(_ context.Context, id uint64, _ string, dead bool) error {
+	p.failed = append(p.failed, failedEvent{id: id, dead: dead})
+	if !dead {
+		return nil
+	}
+
+	for _, e := range p.events {
+		if e.ID != id || e.Type != events.TypeStartProcessingMessage {
+			continue
+		}
+
+		var payload events.StartProcessingMessage
+		if err := json.Unmarshal(e.Payload, &payload); err != nil {
+			return err
+		}
+
+		if p.messages[payload.ID] == models.MessageStatusCreated {
+			p.messages[payload.ID] = models.MessageStatusFailed
+		}
+	}
+
+	return nil
+}
+
+// fakeSubscriber это MessageEventSubscriber, который запоминает ID сообщений, отправленных в очередь.
+type fakeSubscriber struct {
+	queued []uint64
+}
+
+func (s *fakeSubscriber) OnMessageQueued(_ context.Context, id uint64) error {
+	s.queued = append(s.queued, id)
+	return nil
+}
+
+func newTestRelay(sp sarama.SyncProducer, op OutboxProvider, mes MessageEventSubscriber) *Relay {
+	cfg := &config.KafkaConfig{
+		Topics: config.KafkaTopics{ProcessingMessages: "processing-messages"},
+		Outbox: config.OutboxConfig{
+			BatchSize:     10,
+			RetryAttempts: 2,
+			RetryBackoff:  time.Millisecond,
+			MaxAttempts:   3,
+			LockTimeout:   time.Minute,
+		},
+	}
+
+	p := &Producer{
+		cfg:     cfg,
+		sp:      sp,
+		wg:      &sync.WaitGroup{},
+		metrics: metrics.NewProducer(prometheus.NewRegistry()),
+	}
+
+	return NewRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, p, op, mes)
+}
+
+func newTestOutboxEvent(t *testing.T, id uint64, attempts int) models.OutboxEvent {
+	t.Helper()
+
+	payload, err := json.Marshal(events.StartProcessingMessage{ID: id, Content: "content"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return models.OutboxEvent{
+		ID:       id,
+		Type:     events.TypeStartProcessingMessage,
+		Key:      "key",
+		Payload:  payload,
+		Attempts: attempts,
+	}
+}
+
+func TestRelayPendingSent(t *testing.T) {
+	sp := &fakeSyncProducer{send: func(*sarama.ProducerMessage) error { return nil }}
+	op := &fakeOutboxProvider{events: []models.OutboxEvent{newTestOutboxEvent(t, 1, 0), newTestOutboxEvent(t, 2, 0)}}
+	mes := &fakeSubscriber{}
+
+	newTestRelay(sp, op, mes).relayPending(context.Background())
+
+	if op.lease != time.Minute {
+		t.Errorf("claim lease = %s, want %s", op.lease, time.Minute)
+	}
+	if sp.calls != 1 {
+		t.Errorf("SendMessages calls = %d, want 1", sp.calls)
+	}
+	if !slices.Equal(op.sent, []uint64{1, 2}) {
+		t.Errorf("sent events = %v, want [1 2]", op.sent)
+	}
+	if len(op.failed) != 0 {
+		t.Errorf("failed events = %v, want none", op.failed)
+	}
+	if !slices.Equal(mes.queued, []uint64{1, 2}) {
+		t.Errorf("queued messages = %v, want [1 2]", mes.queued)
+	}
+}
+
+func TestRelayPendingFailed(t *testing.T) {
+	errBroker := errors.New("broker is unavailable")
+
+	tests := []struct {
+		name       string
+		attempts   int
+		wantDead   bool
+		wantStatus string
+	}{
+		{name: "attempts left", attempts: 0, wantDead: false, wantStatus: models.MessageStatusCreated},
+		{name: "attempts exhausted", attempts: 2, wantDead: true, wantStatus: models.MessageStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp := &fakeSyncProducer{send: func(msg *sarama.ProducerMessage) error {
+				if string(msg.Value.(sarama.ByteEncoder)) == `{"id":1,"content":"content"}` {
+					return errBroker
+				}
+
+				return nil
+			}}
+			op := &fakeOutboxProvider{
+				events: []models.OutboxEvent{
+					newTestOutboxEvent(t, 1, tt.attempts),
+					newTestOutboxEvent(t, 2, 0),
+				},
+				messages: map[uint64]string{1: models.MessageStatusCreated},
+			}
+			mes := &fakeSubscriber{}
+
+			newTestRelay(sp, op, mes).relayPending(context.Background())
+
+			if sp.calls != 2 {
+				t.Errorf("SendMessages calls = %d, want 2", sp.calls)
+			}
+			if !slices.Equal(op.sent, []uint64{2}) {
+				t.Errorf("sent events = %v, want [2]", op.sent)
+			}
+			if want := []failedEvent{{id: 1, dead: tt.wantDead}}; !slices.Equal(op.failed, want) {
+				t.Errorf("failed events = %v, want %v", op.failed, want)
+			}
+			if !slices.Equal(mes.queued, []uint64{2}) {
+				t.Errorf("queued messages = %v, want [2]", mes.queued)
+			}
+			if status := op.messages[1]; status != tt.wantStatus {
+				t.Errorf("message status = %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRelayPendingUnknownType(t *testing.T) {
+	var sentKeys []string
+	sp := &fakeSyncProducer{send: func(msg *sarama.ProducerMessage) error {
+		sentKeys = append(sentKeys, string(msg.Key.(sarama.StringEncoder)))
+		return nil
+	}}
+
+	unknown := newTestOutboxEvent(t, 1, 0)
+	unknown.Type = "unknown"
+	unknown.Key = "unknown"
+	op := &fakeOutboxProvider{
+		events:   []models.OutboxEvent{unknown, newTestOutboxEvent(t, 2, 0)},
+		messages: map[uint64]string{1: models.MessageStatusCreated},
+	}
+
+	newTestRelay(sp, op, &fakeSubscriber{}).relayPending(context.Background())
+
+	if !slices.Equal(sentKeys, []string{"key"}) {
+		t.Errorf("sent keys = %v, want [key]", sentKeys)
+	}
+	if !slices.Equal(op.sent, []uint64{2}) {
+		t.Errorf("sent events = %v, want [2]", op.sent)
+	}
+	if want := []failedEvent{{id: 1, dead: true}}; !slices.Equal(op.failed, want) {
+		t.Errorf("failed events = %v, want %v", op.failed, want)
+	}
+	if status := op.messages[1]; status != models.MessageStatusCreated {
+		t.Errorf("message status = %s, want %s", status, models.MessageStatusCreated)
+	}
+}
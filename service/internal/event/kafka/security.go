@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+
+	"github.com/sedonn/message-service/internal/config"
+)
+
+// applySASLConfig применяет настройки аутентификации SASL.
+func applySASLConfig(saramaCfg *sarama.Config, cfg *config.KafkaSASLConfig) error {
+	if cfg.Mechanism == "" {
+		return nil
+	}
+
+	if cfg.User == "" || cfg.Password == "" {
+		return errors.New("sasl user and password are required")
+	}
+
+	saramaCfg.Net.SASL.Enable = true
+	saramaCfg.Net.SASL.User = cfg.User
+	saramaCfg.Net.SASL.Password = cfg.Password
+
+	switch cfg.Mechanism {
+	case sarama.SASLTypePlaintext:
+		saramaCfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		saramaCfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		saramaCfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: scram.SHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		saramaCfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		saramaCfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: scram.SHA512}
+		}
+	default:
+		return fmt.Errorf("unknown sasl mechanism: %s", cfg.Mechanism)
+	}
+
+	return nil
+}
+
+// applyTLSConfig применяет настройки TLS-подключения.
+func applyTLSConfig(saramaCfg *sarama.Config, cfg *config.KafkaTLSConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read tls ca file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in tls ca file %s", cfg.CAFile)
+		}
+
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	saramaCfg.Net.TLS.Enable = true
+	saramaCfg.Net.TLS.Config = tlsCfg
+
+	return nil
+}
+
+// scramClient реализует sarama.SCRAMClient на основе github.com/xdg-go/scram.
+type scramClient struct {
+	hashGenerator scram.HashGeneratorFcn
+	conversation  *scram.ClientConversation
+}
+
+var _ sarama.SCRAMClient = (*scramClient)(nil)
+
+// Begin реализует метод sarama.SCRAMClient.Begin.
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+
+	c.conversation = client.NewConversation()
+
+	return nil
+}
+
+// Step реализует метод sarama.SCRAMClient.Step.
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+// Done реализует метод sarama.SCRAMClient.Done.
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
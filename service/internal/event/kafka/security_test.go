@@ -0,0 +1,330 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/sedonn/message-service/internal/config"
+)
+
+// newTestKafkaConfig возвращает конфигурацию Kafka со значениями по умолчанию.
+func newTestKafkaConfig() *config.KafkaConfig {
+	return &config.KafkaConfig{
+		Brokers:  "localhost:9092",
+		ClientID: "message-service",
+		Version:  "2.1.0",
+		Net: config.KafkaNetConfig{
+			DialTimeout:  30 * time.Second,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		},
+		Producer: config.ProducerConfig{
+			Mode:            ProducerModeSync,
+			RequiredAcks:    "leader",
+			Compression:     "none",
+			Partitioner:     "hash",
+			MaxMessageBytes: 1000000,
+			Timeout:         10 * time.Second,
+			RetryMax:        3,
+		},
+		Consumer: config.ConsumerConfig{
+			RebalanceStrategy: "range",
+			InitialOffset:     "newest",
+			SessionTimeout:    10 * time.Second,
+			HeartbeatInterval: 3 * time.Second,
+		},
+	}
+}
+
+// testCerts это пути к файлам сертификатов, сгенерированных для теста.
+type testCerts struct {
+	caFile   string
+	certFile string
+	keyFile  string
+	ca       *x509.Certificate
+}
+
+// generateTestCerts создает удостоверяющий центр и подписанный им клиентский сертификат во временном каталоге.
+func generateTestCerts(t *testing.T) testCerts {
+	t.Helper()
+
+	dir := t.TempDir()
+	certs := testCerts{
+		caFile:   filepath.Join(dir, "ca.pem"),
+		certFile: filepath.Join(dir, "client.pem"),
+		keyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs.ca, err = x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "message-service"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, certs.ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, certs.caFile, "CERTIFICATE", caDER)
+	writePEM(t, certs.certFile, "CERTIFICATE", clientDER)
+	writePEM(t, certs.keyFile, "EC PRIVATE KEY", clientKeyDER)
+
+	return certs
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewSaramaConfigTLS(t *testing.T) {
+	certs := generateTestCerts(t)
+
+	cfg := newTestKafkaConfig()
+	cfg.TLS = config.KafkaTLSConfig{
+		Enabled:    true,
+		CAFile:     certs.caFile,
+		CertFile:   certs.certFile,
+		KeyFile:    certs.keyFile,
+		ServerName: "kafka.local",
+	}
+
+	saramaCfg, err := NewSaramaConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !saramaCfg.Net.TLS.Enable {
+		t.Fatal("tls is not enabled")
+	}
+
+	tlsCfg := saramaCfg.Net.TLS.Config
+	if tlsCfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("min tls version = %x, want %x", tlsCfg.MinVersion, tls.VersionTLS12)
+	}
+	if tlsCfg.ServerName != "kafka.local" {
+		t.Errorf("server name = %q, want %q", tlsCfg.ServerName, "kafka.local")
+	}
+	if tlsCfg.InsecureSkipVerify {
+		t.Error("certificate verification is skipped")
+	}
+
+	// Клиентский сертификат подписан удостоверяющим центром из CAFile, поэтому проверяется по RootCAs.
+	if len(tlsCfg.Certificates) != 1 {
+		t.Fatalf("client certificates = %d, want 1", len(tlsCfg.Certificates))
+	}
+
+	leaf, err := x509.ParseCertificate(tlsCfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:     tlsCfg.RootCAs,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Errorf("client certificate is not verified by root CAs: %v", err)
+	}
+}
+
+func TestNewSaramaConfigTLSInvalid(t *testing.T) {
+	certs := generateTestCerts(t)
+
+	tests := []struct {
+		name    string
+		tls     config.KafkaTLSConfig
+		wantErr string
+	}{
+		{
+			name:    "missing ca file",
+			tls:     config.KafkaTLSConfig{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: "failed to read tls ca file",
+		},
+		{
+			name:    "ca file without certificates",
+			tls:     config.KafkaTLSConfig{Enabled: true, CAFile: certs.keyFile},
+			wantErr: "no certificates found",
+		},
+		{
+			name:    "client certificate without key",
+			tls:     config.KafkaTLSConfig{Enabled: true, CertFile: certs.certFile},
+			wantErr: "failed to load tls client certificate",
+		},
+		{
+			name:    "mismatched client key",
+			tls:     config.KafkaTLSConfig{Enabled: true, CertFile: certs.caFile, KeyFile: certs.keyFile},
+			wantErr: "failed to load tls client certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestKafkaConfig()
+			cfg.TLS = tt.tls
+
+			_, err := NewSaramaConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewSaramaConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSaramaConfigTLSDisabled(t *testing.T) {
+	saramaCfg, err := NewSaramaConfig(newTestKafkaConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saramaCfg.Net.TLS.Enable || saramaCfg.Net.SASL.Enable {
+		t.Errorf("tls enabled = %v, sasl enabled = %v, want both disabled",
+			saramaCfg.Net.TLS.Enable, saramaCfg.Net.SASL.Enable)
+	}
+}
+
+func TestNewSaramaConfigSASL(t *testing.T) {
+	tests := []struct {
+		mechanism string
+		wantSCRAM bool
+	}{
+		{mechanism: sarama.SASLTypePlaintext},
+		{mechanism: sarama.SASLTypeSCRAMSHA256, wantSCRAM: true},
+		{mechanism: sarama.SASLTypeSCRAMSHA512, wantSCRAM: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mechanism, func(t *testing.T) {
+			cfg := newTestKafkaConfig()
+			cfg.SASL = config.KafkaSASLConfig{Mechanism: tt.mechanism, User: "user", Password: "password"}
+
+			saramaCfg, err := NewSaramaConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sasl := saramaCfg.Net.SASL
+			if !sasl.Enable || sasl.User != "user" || sasl.Password != "password" {
+				t.Errorf("sasl enabled = %v, user = %q, password = %q", sasl.Enable, sasl.User, sasl.Password)
+			}
+			if string(sasl.Mechanism) != tt.mechanism {
+				t.Errorf("sasl mechanism = %s, want %s", sasl.Mechanism, tt.mechanism)
+			}
+
+			if !tt.wantSCRAM {
+				if sasl.SCRAMClientGeneratorFunc != nil {
+					t.Error("scram client generator is set for non-scram mechanism")
+				}
+
+				return
+			}
+
+			if sasl.SCRAMClientGeneratorFunc == nil {
+				t.Fatal("scram client generator is not set")
+			}
+
+			// Первое сообщение клиента SCRAM содержит имя пользователя и одноразовый код.
+			client := sasl.SCRAMClientGeneratorFunc()
+			if err := client.Begin("user", "password", ""); err != nil {
+				t.Fatal(err)
+			}
+
+			first, err := client.Step("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(first, "n,,n=user,r=") {
+				t.Errorf("client first message = %q, want prefix %q", first, "n,,n=user,r=")
+			}
+			if client.Done() {
+				t.Error("scram conversation is done after client first message")
+			}
+		})
+	}
+}
+
+func TestNewSaramaConfigSASLInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		sasl    config.KafkaSASLConfig
+		wantErr string
+	}{
+		{
+			name:    "missing password",
+			sasl:    config.KafkaSASLConfig{Mechanism: sarama.SASLTypePlaintext, User: "user"},
+			wantErr: "sasl user and password are required",
+		},
+		{
+			name:    "unknown mechanism",
+			sasl:    config.KafkaSASLConfig{Mechanism: "GSSAPI", User: "user", Password: "password"},
+			wantErr: "unknown sasl mechanism",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestKafkaConfig()
+			cfg.SASL = tt.sasl
+
+			_, err := NewSaramaConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewSaramaConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package consistency
+
+import "context"
+
+// primaryKey это ключ контекста, в котором хранится требование читать данные с основной базы данных.
+type primaryKey struct{}
+
+// WithPrimary возвращает контекст, запросы на чтение в котором выполняются на основной базе данных,
+// а не на репликах. Используется, когда чтение должно увидеть только что записанные данные.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Primary сообщает, требуется ли в контексте чтение с основной базы данных.
+func Primary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
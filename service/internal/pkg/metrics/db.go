@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DB хранит метрики запросов к базе данных.
+type DB struct {
+	duration *prometheus.HistogramVec
+}
+
+// NewDB создает и регистрирует метрики запросов к базе данных.
+func NewDB(reg prometheus.Registerer) *DB {
+	m := &DB{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "Duration of database queries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "table", "status"}),
+	}
+
+	reg.MustRegister(m.duration)
+
+	return m
+}
+
+// ObserveQuery учитывает выполненный запрос к таблице.
+func (m *DB) ObserveQuery(operation, table string, d time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+
+	m.duration.WithLabelValues(operation, table, status).Observe(d.Seconds())
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Producer хранит метрики отправки событий в Kafka.
+type Producer struct {
+	sent     *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewProducer создает и регистрирует метрики отправки событий в Kafka.
+func NewProducer(reg prometheus.Registerer) *Producer {
+	m := &Producer{
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kafka_producer",
+			Name:      "messages_sent_total",
+			Help:      "Number of events successfully sent to Kafka.",
+		}, []string{"topic"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kafka_producer",
+			Name:      "errors_total",
+			Help:      "Number of failed attempts to send events to Kafka.",
+		}, []string{"topic"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "kafka_producer",
+			Name:      "send_duration_seconds",
+			Help:      "Duration of sending events to Kafka.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"topic"}),
+	}
+
+	reg.MustRegister(m.sent, m.errors, m.duration)
+
+	return m
+}
+
+// ObserveSend учитывает попытку отправки события в топик.
+func (m *Producer) ObserveSend(topic string, d time.Duration, err error) {
+	m.duration.WithLabelValues(topic).Observe(d.Seconds())
+
+	if err != nil {
+		m.errors.WithLabelValues(topic).Inc()
+		return
+	}
+
+	m.sent.WithLabelValues(topic).Inc()
+}
+
+// Consumer хранит метрики получения событий из Kafka.
+type Consumer struct {
+	handled    *prometheus.CounterVec
+	duplicates *prometheus.CounterVec
+	failed     *prometheus.CounterVec
+	lag        *prometheus.GaugeVec
+}
+
+// NewConsumer создает и регистрирует метрики получения событий из Kafka.
+func NewConsumer(reg prometheus.Registerer) *Consumer {
+	m := &Consumer{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kafka_consumer",
+			Name:      "messages_handled_total",
+			Help:      "Number of events successfully handled.",
+		}, []string{"topic"}),
+		duplicates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kafka_consumer",
+			Name:      "messages_duplicate_total",
+			Help:      "Number of duplicate or stale events which were skipped.",
+		}, []string{"topic"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kafka_consumer",
+			Name:      "messages_failed_total",
+			Help:      "Number of events which could not be handled after all retries.",
+		}, []string{"topic"}),
+		lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "kafka_consumer",
+			Name:      "lag",
+			Help:      "Number of events in a partition which are not received yet.",
+		}, []string{"topic", "partition"}),
+	}
+
+	reg.MustRegister(m.handled, m.duplicates, m.failed, m.lag)
+
+	return m
+}
+
+// ObserveHandled учитывает успешно обработанное событие.
+func (m *Consumer) ObserveHandled(topic string) {
+	m.handled.WithLabelValues(topic).Inc()
+}
+
+// ObserveDuplicate учитывает повторное или устаревшее событие, которое было пропущено.
+func (m *Consumer) ObserveDuplicate(topic string) {
+	m.duplicates.WithLabelValues(topic).Inc()
+}
+
+// ObserveFailed учитывает событие, которое не удалось обработать.
+func (m *Consumer) ObserveFailed(topic string) {
+	m.failed.WithLabelValues(topic).Inc()
+}
+
+// SetLag устанавливает отставание потребителя в разделе топика.
+func (m *Consumer) SetLag(topic string, partition int32, lag int64) {
+	m.lag.WithLabelValues(topic, strconv.FormatInt(int64(partition), 10)).Set(float64(lag))
+}
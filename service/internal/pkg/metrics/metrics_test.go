@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPObserveRequest(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewHTTP(reg)
+
+	m.ObserveRequest("GET", "/api/v1/messages/:id", 200, 10*time.Millisecond)
+	m.ObserveRequest("GET", "/api/v1/messages/:id", 200, 20*time.Millisecond)
+	m.ObserveRequest("GET", "/api/v1/messages/:id", 404, time.Millisecond)
+
+	expected := `
+# HELP message_service_http_requests_total Number of handled HTTP requests.
+# TYPE message_service_http_requests_total counter
+message_service_http_requests_total{method="GET",route="/api/v1/messages/:id",status="200"} 2
+message_service_http_requests_total{method="GET",route="/api/v1/messages/:id",status="404"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "message_service_http_requests_total"); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(m.duration); n != 2 {
+		t.Errorf("request duration series = %d, want 2", n)
+	}
+}
+
+func TestProducerObserveSend(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewProducer(reg)
+
+	m.ObserveSend("processing-messages", time.Millisecond, nil)
+	m.ObserveSend("processing-messages", time.Millisecond, errors.New("broker is unavailable"))
+
+	if got := testutil.ToFloat64(m.sent.WithLabelValues("processing-messages")); got != 1 {
+		t.Errorf("sent = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("processing-messages")); got != 1 {
+		t.Errorf("errors = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(m.duration, "message_service_kafka_producer_send_duration_seconds"); n != 1 {
+		t.Errorf("send duration series = %d, want 1", n)
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewConsumer(reg)
+
+	m.ObserveHandled("processed-messages")
+	m.ObserveHandled("processed-messages")
+	m.ObserveDuplicate("processed-messages")
+	m.ObserveFailed("acknowledged-messages")
+	m.SetLag("processed-messages", 3, 42)
+
+	expected := `
+# HELP message_service_kafka_consumer_lag Number of events in a partition which are not received yet.
+# TYPE message_service_kafka_consumer_lag gauge
+message_service_kafka_consumer_lag{partition="3",topic="processed-messages"} 42
+# HELP message_service_kafka_consumer_messages_duplicate_total Number of duplicate or stale events which were skipped.
+# TYPE message_service_kafka_consumer_messages_duplicate_total counter
+message_service_kafka_consumer_messages_duplicate_total{topic="processed-messages"} 1
+# HELP message_service_kafka_consumer_messages_failed_total Number of events which could not be handled after all retries.
+# TYPE message_service_kafka_consumer_messages_failed_total counter
+message_service_kafka_consumer_messages_failed_total{topic="acknowledged-messages"} 1
+# HELP message_service_kafka_consumer_messages_handled_total Number of events successfully handled.
+# TYPE message_service_kafka_consumer_messages_handled_total counter
+message_service_kafka_consumer_messages_handled_total{topic="processed-messages"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDBObserveQuery(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewDB(reg)
+
+	m.ObserveQuery("select", "messages", time.Millisecond, nil)
+	m.ObserveQuery("update", "messages", time.Millisecond, errors.New("deadlock detected"))
+
+	if n := testutil.CollectAndCount(m.duration); n != 2 {
+		t.Errorf("query duration series = %d, want 2", n)
+	}
+
+	problems, err := testutil.GatherAndLint(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("%s: %s", p.Metric, p.Text)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewRetention(reg)
+
+	m.ObserveMessages("archived", 3)
+	m.ObserveOutboxEvents(5)
+	m.ObserveIdempotencyKeys(2)
+	m.ObserveRun(time.Second, nil)
+	m.ObserveRun(time.Second, errors.New("database is unavailable"))
+
+	expected := `
+# HELP message_service_retention_idempotency_keys_deleted_total Number of expired idempotency keys deleted.
+# TYPE message_service_retention_idempotency_keys_deleted_total counter
+message_service_retention_idempotency_keys_deleted_total 2
+# HELP message_service_retention_messages_total Number of messages removed by the retention policy.
+# TYPE message_service_retention_messages_total counter
+message_service_retention_messages_total{action="archived"} 3
+# HELP message_service_retention_outbox_events_deleted_total Number of sent outbox events deleted.
+# TYPE message_service_retention_outbox_events_deleted_total counter
+message_service_retention_outbox_events_deleted_total 5
+# HELP message_service_retention_runs_total Number of retention runs.
+# TYPE message_service_retention_runs_total counter
+message_service_retention_runs_total{status="error"} 1
+message_service_retention_runs_total{status="ok"} 1
+`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"message_service_retention_idempotency_keys_deleted_total",
+		"message_service_retention_messages_total",
+		"message_service_retention_outbox_events_deleted_total",
+		"message_service_retention_runs_total",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	reg := NewRegistry()
+
+	// Метрики регистрируются в собственном реестре, поэтому несколько реестров не конфликтуют.
+	NewHTTP(reg)
+	NewHTTP(NewRegistry())
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hasRuntime bool
+	for _, f := range families {
+		if f.GetName() == "go_goroutines" {
+			hasRuntime = true
+		}
+	}
+
+	if !hasRuntime {
+		t.Error("registry has no Go runtime metrics")
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Retention хранит метрики очистки устаревших данных.
+type Retention struct {
+	messages        *prometheus.CounterVec
+	outboxEvents    prometheus.Counter
+	idempotencyKeys prometheus.Counter
+	runs            *prometheus.CounterVec
+	duration        prometheus.Histogram
+}
+
+// NewRetention создает и регистрирует метрики очистки устаревших данных.
+func NewRetention(reg prometheus.Registerer) *Retention {
+	m := &Retention{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "messages_total",
+			Help:      "Number of messages removed by the retention policy.",
+		}, []string{"action"}),
+		outboxEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "outbox_events_deleted_total",
+			Help:      "Number of sent outbox events deleted.",
+		}),
+		idempotencyKeys: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "idempotency_keys_deleted_total",
+			Help:      "Number of expired idempotency keys deleted.",
+		}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "runs_total",
+			Help:      "Number of retention runs.",
+		}, []string{"status"}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "run_duration_seconds",
+			Help:      "Duration of retention runs.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		}),
+	}
+
+	reg.MustRegister(m.messages, m.outboxEvents, m.idempotencyKeys, m.runs, m.duration)
+
+	return m
+}
+
+// ObserveMessages учитывает сообщения, удаленные или перенесенные в архив в соответствии с action.
+func (m *Retention) ObserveMessages(action string, n int64) {
+	m.messages.WithLabelValues(action).Add(float64(n))
+}
+
+// ObserveOutboxEvents учитывает удаленные отправленные события outbox.
+func (m *Retention) ObserveOutboxEvents(n int64) {
+	m.outboxEvents.Add(float64(n))
+}
+
+// ObserveIdempotencyKeys учитывает удаленные истекшие ключи идемпотентности.
+func (m *Retention) ObserveIdempotencyKeys(n int64) {
+	m.idempotencyKeys.Add(float64(n))
+}
+
+// ObserveRun учитывает завершенный запуск очистки.
+func (m *Retention) ObserveRun(d time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+
+	m.runs.WithLabelValues(status).Inc()
+	m.duration.Observe(d.Seconds())
+}
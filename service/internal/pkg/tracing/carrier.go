@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// producerMessageCarrier передает контекст трассировки через заголовки отправляемого сообщения Kafka.
+type producerMessageCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+var _ propagation.TextMapCarrier = producerMessageCarrier{}
+
+// Get реализует метод propagation.TextMapCarrier.
+func (c producerMessageCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+// Set реализует метод propagation.TextMapCarrier.
+func (c producerMessageCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys реализует метод propagation.TextMapCarrier.
+func (c producerMessageCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		keys = append(keys, string(h.Key))
+	}
+
+	return keys
+}
+
+// consumerMessageCarrier извлекает контекст трассировки из заголовков полученного сообщения Kafka.
+type consumerMessageCarrier struct {
+	msg *sarama.ConsumerMessage
+}
+
+var _ propagation.TextMapCarrier = consumerMessageCarrier{}
+
+// Get реализует метод propagation.TextMapCarrier.
+func (c consumerMessageCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+// Set реализует метод propagation.TextMapCarrier. Заголовки полученного сообщения не изменяются.
+func (c consumerMessageCarrier) Set(string, string) {}
+
+// Keys реализует метод propagation.TextMapCarrier.
+func (c consumerMessageCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+
+	return keys
+}
+
+// InjectProducerMessage добавляет контекст трассировки в заголовки отправляемого сообщения Kafka.
+func InjectProducerMessage(ctx context.Context, msg *sarama.ProducerMessage) {
+	otel.GetTextMapPropagator().Inject(ctx, producerMessageCarrier{msg: msg})
+}
+
+// ExtractConsumerMessage возвращает контекст с контекстом трассировки из заголовков полученного сообщения Kafka.
+func ExtractConsumerMessage(ctx context.Context, msg *sarama.ConsumerMessage) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, consumerMessageCarrier{msg: msg})
+}
+
+// InjectMap сохраняет контекст трассировки в виде словаря, например для отложенной отправки события.
+func InjectMap(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return carrier
+}
+
+// ExtractMap возвращает контекст с контекстом трассировки, сохраненным InjectMap.
+func ExtractMap(ctx context.Context, m map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(m))
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sedonn/message-service/internal/config"
+)
+
+// newTestProvider устанавливает глобальный провайдер трассировок, который сохраняет спаны в памяти.
+func newTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := NewWithOptions(sdktrace.WithSyncer(exp))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	return exp
+}
+
+func TestKafkaHeadersPropagation(t *testing.T) {
+	exp := newTestProvider(t)
+	tracer := otel.Tracer("test")
+
+	ctx, publish := tracer.Start(context.Background(), "publish", trace.WithSpanKind(trace.SpanKindProducer))
+	msg := &sarama.ProducerMessage{Topic: "processing-messages"}
+	InjectProducerMessage(ctx, msg)
+	// Повторная передача контекста заменяет заголовок, а не добавляет новый.
+	InjectProducerMessage(ctx, msg)
+	publish.End()
+
+	if len(msg.Headers) != 1 || string(msg.Headers[0].Key) != "traceparent" {
+		t.Fatalf("headers = %v, want single traceparent header", msg.Headers)
+	}
+
+	received := &sarama.ConsumerMessage{Topic: msg.Topic}
+	for i := range msg.Headers {
+		received.Headers = append(received.Headers, &msg.Headers[i])
+	}
+
+	_, process := tracer.Start(ExtractConsumerMessage(context.Background(), received), "process")
+	process.End()
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+
+	producerSpan, consumerSpan := spans[0], spans[1]
+	if consumerSpan.Parent.TraceID() != producerSpan.SpanContext.TraceID() {
+		t.Errorf("consumer trace = %s, want %s", consumerSpan.Parent.TraceID(), producerSpan.SpanContext.TraceID())
+	}
+	if consumerSpan.Parent.SpanID() != producerSpan.SpanContext.SpanID() {
+		t.Errorf("consumer parent = %s, want %s", consumerSpan.Parent.SpanID(), producerSpan.SpanContext.SpanID())
+	}
+	if !consumerSpan.Parent.IsRemote() {
+		t.Error("consumer parent is not remote")
+	}
+}
+
+func TestMapPropagation(t *testing.T) {
+	exp := newTestProvider(t)
+	tracer := otel.Tracer("test")
+
+	ctx, request := tracer.Start(context.Background(), "request")
+	headers := InjectMap(ctx)
+	request.End()
+
+	_, relay := tracer.Start(ExtractMap(context.Background(), headers), "relay")
+	relay.End()
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+
+	if spans[1].Parent.SpanID() != spans[0].SpanContext.SpanID() {
+		t.Errorf("relay parent = %s, want %s", spans[1].Parent.SpanID(), spans[0].SpanContext.SpanID())
+	}
+}
+
+func TestExtractWithoutHeaders(t *testing.T) {
+	newTestProvider(t)
+
+	ctx := ExtractConsumerMessage(context.Background(), &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{nil}})
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Error("span context extracted from message without headers")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		exporter string
+		wantErr  bool
+	}{
+		{exporter: ExporterNone},
+		{exporter: ExporterStdout},
+		{exporter: "jaeger", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.exporter, func(t *testing.T) {
+			tp, err := New(&config.TracingConfig{Exporter: tt.exporter, SampleRatio: 1})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tp != nil {
+				_ = tp.Shutdown(context.Background())
+			}
+		})
+	}
+}
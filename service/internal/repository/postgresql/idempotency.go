@@ -0,0 +1,62 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// errIdempotencyKeyExists возникает при сохранении ключа идемпотентности, который уже действует.
+var errIdempotencyKeyExists = errors.New("idempotency key exists")
+
+// saveIdempotencyKey сохраняет ключ идемпотентности в транзакции tx, предварительно удаляя истекший ключ.
+//
+// Если действующий ключ уже существует, возвращает errIdempotencyKeyExists. Конкурентная вставка того же ключа
+// ожидает завершения транзакции, которая вставила его первой.
+func saveIdempotencyKey(tx *gorm.DB, key models.IdempotencyKey, messageID uint64) error {
+	err := tx.
+		Where("key = ? AND expires_at <= now()", key.Key).
+		Delete(&models.IdempotencyKey{}).
+		Error
+	if err != nil {
+		return err
+	}
+
+	key.MessageID = messageID
+	res := tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&key)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return errIdempotencyKeyExists
+	}
+
+	return nil
+}
+
+// replayIdempotencyKey возвращает ID сообщения, ранее созданного по ключу идемпотентности.
+func (r *Repository) replayIdempotencyKey(ctx context.Context, key models.IdempotencyKey) (uint64, bool, error) {
+	var existing models.IdempotencyKey
+	tx := r.db.
+		WithContext(ctx).
+		Where("key = ?", key.Key).
+		Take(&existing)
+
+	if tx.Error != nil {
+		return 0, false, tx.Error
+	}
+
+	if existing.RequestHash != key.RequestHash {
+		return 0, false, models.ErrIdempotencyKeyConflict
+	}
+
+	return existing.MessageID, true, nil
+}
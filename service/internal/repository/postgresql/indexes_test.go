@@ -0,0 +1,225 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// testPostgresDSNEnv это переменная окружения со строкой подключения к тестовой базе данных PostgreSQL 16+.
+// Тесты применяют к базе данных миграции, поэтому она должна быть отдельной.
+const testPostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// openTestDB подключается к тестовой базе данных, применяет миграции и создает секции сообщений.
+// Если строка подключения не задана, тест пропускается, а в CI завершается ошибкой.
+// Локально тесты с базой данных запускаются задачей test из taskfile.yaml.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(testPostgresDSNEnv)
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s is not set", testPostgresDSNEnv)
+		}
+
+		t.Skipf("%s is not set", testPostgresDSNEnv)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		SkipDefaultTransaction: true,
+		Logger:                 gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	migrator, err := newMigrator(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Repository{db: db}
+	if _, err := r.CreateMessagePartitions(context.Background(), time.Now().AddDate(0, -1, 0), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// explain возвращает обобщенный план запроса, который не зависит от значений параметров.
+// Такой план используется для подготовленных запросов, поэтому частичный индекс попадает в него,
+// только если условие индекса следует из условия запроса без учета параметров.
+//
+// Последовательное сканирование отключается, чтобы на пустых таблицах планировщик выбирал индекс,
+// если он применим к запросу.
+func explain(t *testing.T, db *gorm.DB, stmt *gorm.Statement) string {
+	t.Helper()
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var plan strings.Builder
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("unexpected database driver connection")
+		}
+
+		// Запрос с параметрами $1, $2, ... отправляется по простому протоколу без значений параметров.
+		results, err := c.Conn().PgConn().Exec(
+			context.Background(),
+			"SET enable_seqscan = off; EXPLAIN (GENERIC_PLAN) "+stmt.SQL.String(),
+		).ReadAll()
+		if err != nil {
+			return err
+		}
+
+		for _, row := range results[len(results)-1].Rows {
+			plan.Write(row[0])
+			plan.WriteByte('\n')
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return plan.String()
+}
+
+// indexNames возвращает имя индекса и имена его индексов в секциях таблицы.
+func indexNames(t *testing.T, db *gorm.DB, index string) []string {
+	t.Helper()
+
+	var names []string
+	err := db.
+		Raw(`SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = ?::regclass`, index).
+		Scan(&names).
+		Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(names, index)
+}
+
+func TestQueriesUseIndexes(t *testing.T) {
+	db := openTestDB(t)
+	dryRun := db.Session(&gorm.Session{DryRun: true})
+
+	now := time.Now()
+	filteredPage := func(f models.MessageFilter, sort models.MessageSort, after *models.Cursor) *gorm.Statement {
+		return dryRun.
+			Scopes(filterMessages(f), paginate(sort, models.PageRequest{After: after, Limit: 10})).
+			Find(&[]models.Message{}).
+			Statement
+	}
+	page := func(sort models.MessageSort, after *models.Cursor) *gorm.Statement {
+		return filteredPage(models.MessageFilter{}, sort, after)
+	}
+	unprocessed := []string{models.MessageStatusCreated, models.MessageStatusQueued, models.MessageStatusProcessing}
+
+	tests := []struct {
+		name  string
+		stmt  *gorm.Statement
+		index string
+	}{
+		{
+			name:  "stuck messages",
+			stmt:  stuckMessages(dryRun, now, 100).Find(&[]models.Message{}).Statement,
+			index: "idx_messages_dispatched",
+		},
+		{
+			name:  "messages by created_at",
+			stmt:  page(models.MessageSort{Field: models.SortByCreatedAt, Order: models.SortOrderAsc}, nil),
+			index: "idx_messages_created_at_id",
+		},
+		{
+			name: "messages by created_at after cursor",
+			stmt: page(
+				models.MessageSort{Field: models.SortByCreatedAt, Order: models.SortOrderDesc},
+				&models.Cursor{Value: &now, ID: 1},
+			),
+			index: "idx_messages_created_at_id",
+		},
+		{
+			name: "unprocessed messages by created_at",
+			stmt: filteredPage(
+				models.MessageFilter{Statuses: unprocessed},
+				models.MessageSort{Field: models.SortByCreatedAt, Order: models.SortOrderAsc},
+				nil,
+			),
+			index: "idx_messages_unprocessed",
+		},
+		{
+			name: "created messages by created_at after cursor",
+			stmt: filteredPage(
+				models.MessageFilter{Statuses: []string{models.MessageStatusCreated}},
+				models.MessageSort{Field: models.SortByCreatedAt, Order: models.SortOrderDesc},
+				&models.Cursor{Value: &now, ID: 1},
+			),
+			index: "idx_messages_unprocessed",
+		},
+		{
+			name:  "messages by processed_at",
+			stmt:  page(models.MessageSort{Field: models.SortByProcessedAt, Order: models.SortOrderAsc}, nil),
+			index: "idx_messages_processed_at_id",
+		},
+		{
+			name:  "outbox events claim",
+			stmt:  claimableOutboxEvents(dryRun, 100).Find(&[]models.OutboxEvent{}).Statement,
+			index: "idx_outbox_events_pending",
+		},
+		{
+			name:  "completed messages purge",
+			stmt:  dryRun.Exec(deleteCompletedMessagesQuery, map[string]any{"before": now, "limit": 100}).Statement,
+			index: "idx_messages_completed",
+		},
+		{
+			name:  "sent outbox events purge",
+			stmt:  dryRun.Exec(deleteSentOutboxEventsQuery, map[string]any{"before": now, "limit": 100}).Statement,
+			index: "idx_outbox_events_sent_at",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := explain(t, db, tt.stmt)
+
+			names := indexNames(t, db, tt.index)
+			if !slices.ContainsFunc(names, func(name string) bool { return strings.Contains(plan, " "+name+" ") }) {
+				t.Errorf("plan does not use %s:\n%s", tt.index, plan)
+			}
+		})
+	}
+}
@@ -2,80 +2,362 @@ package postgresql
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
 
-	"github.com/sedonn/message-service/internal/domain/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
 )
 
-// Messages возвращает данные о всех сообщениях.
-func (r *Repository) Messages(ctx context.Context, pageID, pageSize uint) ([]models.Message, error) {
-	var messages []models.Message
-	tx := r.db.
+// insertBatchSize это максимальное количество строк одной многострочной вставки.
+// Ограничение не дает превысить предел PostgreSQL в 65535 параметров запроса при любом размере пакета.
+const insertBatchSize = 1000
+
+// messageStatusValues содержит все статусы сообщения, которые могут быть записаны в условие запроса.
+var messageStatusValues = []string{
+	models.MessageStatusCreated,
+	models.MessageStatusQueued,
+	models.MessageStatusProcessing,
+	models.MessageStatusProcessed,
+	models.MessageStatusFailed,
+	models.MessageStatusCancelled,
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Message возвращает данные сообщения по его ID.
+//
+// Если сообщение не найдено на реплике, оно ищется на основной базе данных, поскольку только что
+// созданное сообщение могло еще не попасть на реплику.
+func (r *Repository) Message(ctx context.Context, id uint64) (models.Message, error) {
+	db := r.reader(ctx)
+	message, err := findMessage(ctx, db, id)
+	if errors.Is(err, models.ErrMessageNotFound) && db != r.db {
+		return findMessage(ctx, r.db, id)
+	}
+
+	return message, err
+}
+
+// findMessage возвращает данные сообщения по его ID из базы данных db.
+func findMessage(ctx context.Context, db *gorm.DB, id uint64) (models.Message, error) {
+	var message models.Message
+	tx := db.
 		WithContext(ctx).
-		Scopes(paginate(pageID, pageSize)).
-		Find(&messages)
+		First(&message, id)
 
 	if tx.Error != nil {
-		return nil, tx.Error
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return models.Message{}, models.ErrMessageNotFound
+		}
+
+		return models.Message{}, tx.Error
 	}
 
-	return messages, nil
+	return message, nil
 }
 
-// ProcessedMessages возвращает данные только обработанных сообщений.
-func (r *Repository) ProcessedMessages(ctx context.Context, pageID, pageSize uint) ([]models.Message, error) {
+// Messages возвращает страницу сообщений, удовлетворяющих запросу.
+func (r *Repository) Messages(ctx context.Context, q models.MessageQuery) (models.MessagePage, error) {
 	var messages []models.Message
-	tx := r.db.
+	tx := r.reader(ctx).
 		WithContext(ctx).
-		Scopes(paginate(pageID, pageSize), messageProcessed).
+		Scopes(filterMessages(q.Filter), paginate(q.Sort, q.Page)).
 		Find(&messages)
 
 	if tx.Error != nil {
-		return nil, tx.Error
+		return models.MessagePage{}, tx.Error
 	}
 
-	return messages, nil
+	return newMessagePage(messages, q.Sort, q.Page.Limit), nil
+}
+
+// SaveMessage сохраняет данные нового сообщения и событие старта его обработки в одной транзакции.
+//
+// Если передан ключ идемпотентности, он сохраняется в той же транзакции. Если действующий ключ уже существует,
+// сообщение не сохраняется, а возвращается ID сообщения, созданного по этому ключу ранее, и признак повтора.
+// Если ключ использован для запроса с другим хешем, возвращается models.ErrIdempotencyKeyConflict.
+func (r *Repository) SaveMessage(ctx context.Context, m models.Message, key *models.IdempotencyKey) (uint64, bool, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&m).Error; err != nil {
+			return err
+		}
+
+		e, err := newOutboxEvent(ctx, events.TypeStartProcessingMessage, events.StartProcessingMessage{
+			ID:      m.ID,
+			Content: m.Content,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(&e).Error; err != nil {
+			return err
+		}
+
+		if key == nil {
+			return nil
+		}
+
+		return saveIdempotencyKey(tx, *key, m.ID)
+	})
+
+	if errors.Is(err, errIdempotencyKeyExists) {
+		return r.replayIdempotencyKey(ctx, *key)
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	return m.ID, false, nil
 }
 
-// UnprocessedMessages возвращает данные только необработанных сообщений.
-func (r *Repository) UnprocessedMessages(ctx context.Context, pageID, pageSize uint) ([]models.Message, error) {
+// SaveMessages сохраняет данные новых сообщений и события старта их обработки в одной транзакции.
+// Сообщения и события сохраняются многострочными вставками не более чем по insertBatchSize строк.
+// Возвращает ID сообщений в порядке их передачи.
+func (r *Repository) SaveMessages(ctx context.Context, messages []models.Message) ([]uint64, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&messages, insertBatchSize).Error; err != nil {
+			return err
+		}
+
+		outboxEvents := make([]models.OutboxEvent, 0, len(messages))
+		for _, m := range messages {
+			e, err := newOutboxEvent(ctx, events.TypeStartProcessingMessage, events.StartProcessingMessage{
+				ID:      m.ID,
+				Content: m.Content,
+			})
+			if err != nil {
+				return err
+			}
+
+			outboxEvents = append(outboxEvents, e)
+		}
+
+		return tx.CreateInBatches(&outboxEvents, insertBatchSize).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+
+	return ids, nil
+}
+
+// UpdateMessageStatus изменяет статус сообщения, если его текущий статус равен from.
+//
+// Если передано время обработки, вместе со статусом сохраняется результат обработки.
+// При отмене сообщения в той же транзакции удаляются неотправленные события старта его обработки,
+// чтобы отмененное сообщение не было отправлено обработчику.
+// Возвращает models.ErrMessageStatusConflict, если статус сообщения уже изменен,
+// и models.ErrMessageNotFound, если сообщения не существует.
+func (r *Repository) UpdateMessageStatus(ctx context.Context, id uint64, from string, u models.MessageStatusUpdate) error {
+	values := map[string]any{"status": u.Status}
+	if u.ProcessedAt != nil {
+		values["processed_at"] = u.ProcessedAt
+		values["result"] = u.Result
+		values["error_reason"] = u.ErrorReason
+	}
+	if u.DispatchedAt != nil {
+		values["dispatched_at"] = u.DispatchedAt
+	}
+
+	var updated bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.
+			Model(&models.Message{ID: id}).
+			Scopes(messageStatuses([]string{from})).
+			Updates(values)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+
+		updated = true
+		if u.Status != models.MessageStatusCancelled {
+			return nil
+		}
+
+		return deletePendingMessageEvents(tx, id)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if updated {
+		return nil
+	}
+
+	if _, err := findMessage(ctx, r.db, id); err != nil {
+		return err
+	}
+
+	return models.ErrMessageStatusConflict
+}
+
+// ClaimStuckMessages захватывает отправленные обработчику, но не обработанные сообщения,
+// последняя отправка которых была раньше before, и сдвигает время их последней отправки.
+// Счетчик отправок не изменяется, он увеличивается только после успешной повторной отправки.
+//
+// Строки блокируются через FOR UPDATE SKIP LOCKED, поэтому одновременно работающие реплики
+// захватывают разные сообщения. Захваченное сообщение не будет захвачено снова до истечения таймаута.
+func (r *Repository) ClaimStuckMessages(ctx context.Context, before time.Time, limit int) ([]models.Message, error) {
 	var messages []models.Message
-	tx := r.db.
-		WithContext(ctx).
-		Scopes(paginate(pageID, pageSize), messageUnprocessed).
-		Find(&messages)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := stuckMessages(tx, before, limit).Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
 
-	if tx.Error != nil {
-		return nil, tx.Error
+		now := time.Now()
+		err = tx.
+			Model(&messages).
+			Update("dispatched_at", now).
+			Error
+		if err != nil {
+			return err
+		}
+
+		for i := range messages {
+			messages[i].DispatchedAt = &now
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return messages, nil
 }
 
-// SaveMessage сохраняет данные нового сообщения.
-func (r *Repository) SaveMessage(ctx context.Context, m models.Message) (uint64, error) {
-	if tx := r.db.WithContext(ctx).Create(&m); tx.Error != nil {
-		return 0, tx.Error
-	}
+// MarkMessageRedispatched увеличивает счетчик отправок сообщения, которое еще не обработано.
+func (r *Repository) MarkMessageRedispatched(ctx context.Context, id uint64) error {
+	tx := r.db.
+		WithContext(ctx).
+		Model(&models.Message{ID: id}).
+		Scopes(messageDispatched).
+		Update("dispatch_attempts", gorm.Expr("dispatch_attempts + 1"))
 
-	return m.ID, nil
+	return tx.Error
 }
 
-// UpdateMessage обновляет данные существующего сообщения.
-func (r *Repository) UpdateMessage(ctx context.Context, m models.Message) (models.Message, error) {
-	if tx := r.db.WithContext(ctx).Updates(&m); tx.Error != nil {
-		return models.Message{}, tx.Error
+// filterMessages применяет все заданные условия отбора сообщений.
+func filterMessages(f models.MessageFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(f.IDs) > 0 {
+			db = db.Scopes(messageIDs(f.IDs))
+		}
+
+		if len(f.Statuses) > 0 {
+			db = db.Scopes(messageStatuses(f.Statuses))
+		}
+
+		if f.Content != "" {
+			db = db.Scopes(messageContentContains(f.Content))
+		}
+
+		if f.CreatedFrom != nil || f.CreatedTo != nil {
+			db = db.Scopes(messageCreatedBetween(f.CreatedFrom, f.CreatedTo))
+		}
+
+		if f.ProcessedFrom != nil || f.ProcessedTo != nil {
+			db = db.Scopes(messageProcessedBetween(f.ProcessedFrom, f.ProcessedTo))
+		}
+
+		return db
 	}
+}
 
-	return m, nil
+// stuckMessages возвращает запрос очередного пакета зависших сообщений, доступных для захвата.
+func stuckMessages(db *gorm.DB, before time.Time, limit int) *gorm.DB {
+	return db.
+		Scopes(messageDispatched, messageDispatchedBefore(before)).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Order("dispatched_at, id").
+		Limit(limit)
 }
 
-// messageProcessed фильтрует только обработанные сообщения.
+// messageProcessed фильтрует сообщения, обработка которых завершена успешно или с ошибкой.
 func messageProcessed(db *gorm.DB) *gorm.DB {
 	return db.Where("processed_at IS NOT NULL")
 }
 
-// messageUnprocessed фильтрует только необработанные сообщения.
-func messageUnprocessed(db *gorm.DB) *gorm.DB {
-	return db.Where("processed_at IS NULL")
+// messageDispatched фильтрует сообщения, которые отправлены обработчику, но еще не обработаны.
+// Созданные сообщения не попадают в выборку, пока их событие старта обработки не отправлено из outbox.
+//
+// Условие записано без параметров и совпадает с условием частичного индекса idx_messages_dispatched,
+// иначе планировщик не сможет использовать индекс в подготовленных запросах.
+func messageDispatched(db *gorm.DB) *gorm.DB {
+	return db.Where("status IN ('queued', 'processing') AND dispatched_at IS NOT NULL")
+}
+
+// messageDispatchedBefore фильтрует сообщения, последняя отправка которых была раньше before.
+func messageDispatchedBefore(before time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("dispatched_at < ?", before)
+	}
+}
+
+// messageStatuses фильтрует сообщения с указанными статусами.
+//
+// Статусы записываются в условие литералами, иначе планировщик не сможет использовать частичные индексы
+// по статусу, например idx_messages_unprocessed, в подготовленных запросах. В условие попадают только
+// известные статусы, поэтому значения не требуют экранирования.
+func messageStatuses(statuses []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		literals := make([]string, 0, len(statuses))
+		for _, status := range statuses {
+			if slices.Contains(messageStatusValues, status) {
+				literals = append(literals, "'"+status+"'")
+			}
+		}
+
+		if len(literals) == 0 {
+			return db.Where("FALSE")
+		}
+
+		return db.Where("status IN (" + strings.Join(literals, ", ") + ")")
+	}
+}
+
+// messageIDs фильтрует сообщения с указанными ID.
+func messageIDs(ids []uint64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ?", ids)
+	}
+}
+
+// messageContentContains фильтрует сообщения, содержащие подстроку без учета регистра.
+func messageContentContains(substr string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("content ILIKE ?", "%"+likeEscaper.Replace(substr)+"%")
+	}
+}
+
+// messageCreatedBetween фильтрует сообщения, созданные в полуинтервале [from, to).
+func messageCreatedBetween(from, to *time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(timeBetween("created_at", from, to))
+	}
+}
+
+// messageProcessedBetween фильтрует сообщения, обработанные в полуинтервале [from, to).
+func messageProcessedBetween(from, to *time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(timeBetween("processed_at", from, to))
+	}
 }
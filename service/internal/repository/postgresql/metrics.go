@@ -0,0 +1,70 @@
+package postgresql
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+)
+
+// metricsStartKey это ключ, по которому в запросе хранится время его начала.
+const metricsStartKey = "metrics:start"
+
+// metricsPlugin это плагин GORM, который учитывает длительность запросов к базе данных.
+type metricsPlugin struct {
+	m *metrics.DB
+}
+
+var _ gorm.Plugin = (*metricsPlugin)(nil)
+
+// Name реализует метод gorm.Plugin.
+func (p *metricsPlugin) Name() string { return "metrics" }
+
+// Initialize реализует метод gorm.Plugin.
+func (p *metricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", p.before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", p.after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", p.before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", p.after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", p.before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", p.after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", p.before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", p.after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", p.before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", p.after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", p.before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", p.after("raw")),
+	)
+}
+
+// before запоминает время начала запроса.
+func (p *metricsPlugin) before(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+// after учитывает длительность завершенного запроса.
+func (p *metricsPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+
+		p.m.ObserveQuery(operation, db.Statement.Table, time.Since(start), err)
+	}
+}
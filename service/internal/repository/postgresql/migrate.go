@@ -0,0 +1,322 @@
+package postgresql
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/repository/postgresql/migrations"
+)
+
+// migrationLockID это ключ advisory-блокировки, под которой применяются миграции.
+// Блокировка не дает нескольким репликам применять миграции одновременно.
+const migrationLockID int64 = 4_815_162_342
+
+// migrationFilePattern это шаблон имени файла миграции.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrSchemaOutdated возникает, если в базе данных применены не все миграции.
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+// migration это версионированная миграция схемы базы данных.
+type migration struct {
+	version uint64
+	name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus хранит состояние миграции. AppliedAt пуст, если миграция не применена.
+type MigrationStatus struct {
+	Version   uint64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator применяет и откатывает миграции схемы базы данных.
+type Migrator struct {
+	db         *sql.DB
+	migrations []migration
+}
+
+// NewMigrator создает Migrator с отдельным подключением к базе данных.
+func NewMigrator(cfg *config.Config) (*Migrator, error) {
+	db, err := gorm.Open(postgres.Open(makeDSN(&cfg.DB)), &gorm.Config{
+		Logger: logger.NewGORMLogger(cfg.Env),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMigrator(sqlDB)
+}
+
+// newMigrator создает Migrator, использующий переданное подключение к базе данных.
+func newMigrator(db *sql.DB) (*Migrator, error) {
+	ms, err := loadMigrations(migrations.FS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	return &Migrator{db: db, migrations: ms}, nil
+}
+
+// Close закрывает подключение к базе данных.
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Up применяет все непримененные миграции и возвращает их.
+func (m *Migrator) Up(ctx context.Context) ([]MigrationStatus, error) {
+	var applied []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mg := range m.migrations {
+			if _, ok := versions[mg.version]; ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, mg.version, mg.name, mg.up, true); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			applied = append(applied, MigrationStatus{Version: mg.version, Name: mg.name, AppliedAt: &now})
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down откатывает последнюю примененную миграцию и возвращает ее.
+// Если примененных миграций нет, возвращает nil.
+func (m *Migrator) Down(ctx context.Context) (*MigrationStatus, error) {
+	var reverted *MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := versions[mg.version]; !ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, mg.version, mg.name, mg.down, false); err != nil {
+				return err
+			}
+
+			reverted = &MigrationStatus{Version: mg.version, Name: mg.name}
+
+			return nil
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status возвращает состояние всех известных миграций в порядке их применения.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]MigrationStatus, len(m.migrations))
+		for i, mg := range m.migrations {
+			statuses[i] = MigrationStatus{Version: mg.version, Name: mg.name}
+			if appliedAt, ok := versions[mg.version]; ok {
+				statuses[i].AppliedAt = &appliedAt
+			}
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// EnsureUpToDate возвращает ErrSchemaOutdated, если в базе данных применены не все миграции.
+func (m *Migrator) EnsureUpToDate(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending int
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migrations", ErrSchemaOutdated, pending)
+	}
+
+	return nil
+}
+
+// withLock выполняет fn на отдельном подключении под advisory-блокировкой миграций.
+// Перед выполнением fn создает таблицу версий схемы, если ее нет.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to release migration lock: %w", unlockErr))
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// apply выполняет SQL миграции и фиксирует ее применение или откат в одной транзакции.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version uint64, name, query string, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to run migration %d_%s: %w", version, name, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_version (version, name) VALUES ($1, $2)", version, name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_version WHERE version = $1", version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", version, name, err)
+	}
+
+	return tx.Commit()
+}
+
+// appliedVersions возвращает время применения примененных миграций по их версиям.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[uint64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[uint64]time.Time)
+	for rows.Next() {
+		var (
+			version   uint64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
+
+// loadMigrations загружает миграции из файловой системы и упорядочивает их по версии.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*migration)
+	for _, f := range files {
+		match := migrationFilePattern.FindStringSubmatch(f.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", f.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, f.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &migration{version: version, name: match[2]}
+			byVersion[version] = mg
+		}
+
+		if mg.name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, mg.name, match[2])
+		}
+
+		if match[3] == "up" {
+			mg.up = string(content)
+		} else {
+			mg.down = string(content)
+		}
+	}
+
+	ms := make([]migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.up == "" || mg.down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", mg.version, mg.name)
+		}
+
+		ms = append(ms, *mg)
+	}
+
+	slices.SortFunc(ms, func(a, b migration) int {
+		return cmp.Compare(a.version, b.version)
+	})
+
+	return ms, nil
+}
@@ -0,0 +1,42 @@
+-- Начальная схема. Совпадает со схемой, которую создавал AutoMigrate, поэтому может быть
+-- применена к базе данных, созданной им ранее.
+CREATE TABLE IF NOT EXISTS messages (
+    id           bigserial PRIMARY KEY,
+    content      varchar(256),
+    created_at   timestamptz,
+    processed_at timestamptz DEFAULT NULL
+);
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS status            varchar(16) NOT NULL DEFAULT 'created',
+    ADD COLUMN IF NOT EXISTS result            text DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS error_reason      text DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS dispatch_attempts bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS dispatched_at     timestamptz DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_status ON messages (status);
+
+-- Сообщения, обработанные до появления статусов, получают статус по результату обработки.
+UPDATE messages
+SET status = CASE WHEN error_reason IS NULL THEN 'processed' ELSE 'failed' END
+WHERE status = 'created' AND processed_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id         bigserial PRIMARY KEY,
+    type       varchar(64) NOT NULL,
+    key        varchar(64) NOT NULL,
+    payload    jsonb NOT NULL,
+    headers    jsonb,
+    attempts   bigint NOT NULL DEFAULT 0,
+    last_error text DEFAULT NULL,
+    created_at timestamptz,
+    sent_at    timestamptz DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key          varchar(255) PRIMARY KEY,
+    request_hash varchar(64) NOT NULL,
+    message_id   bigint NOT NULL,
+    created_at   timestamptz,
+    expires_at   timestamptz NOT NULL
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+DROP INDEX IF EXISTS idx_messages_unprocessed;
+DROP INDEX IF EXISTS idx_messages_processed_at_id;
+DROP INDEX IF EXISTS idx_messages_created_at_id;
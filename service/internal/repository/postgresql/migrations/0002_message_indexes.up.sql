@@ -0,0 +1,10 @@
+-- Порядок постраничной навигации по дате создания и по дате обработки.
+CREATE INDEX IF NOT EXISTS idx_messages_created_at_id ON messages (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_messages_processed_at_id ON messages (processed_at, id);
+
+-- Необработанные сообщения составляют малую часть таблицы. Условие индекса должно следовать
+-- из условия отбора сообщений по статусу в репозитории, см. messageStatuses.
+CREATE INDEX IF NOT EXISTS idx_messages_unprocessed ON messages (created_at, id)
+    WHERE status IN ('created', 'queued', 'processing');
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (id) WHERE sent_at IS NULL;
@@ -0,0 +1,19 @@
+-- Архив сообщений, удаленных политикой хранения.
+CREATE TABLE IF NOT EXISTS messages_archive (
+    id                bigint PRIMARY KEY,
+    content           varchar(256),
+    status            varchar(16) NOT NULL,
+    created_at        timestamptz,
+    processed_at      timestamptz,
+    result            text,
+    error_reason      text,
+    dispatch_attempts bigint NOT NULL DEFAULT 0,
+    dispatched_at     timestamptz,
+    archived_at       timestamptz NOT NULL DEFAULT now()
+);
+
+-- Завершенные сообщения отбираются для удаления в порядке создания.
+CREATE INDEX IF NOT EXISTS idx_messages_completed ON messages (created_at, id)
+    WHERE status IN ('processed', 'failed', 'cancelled');
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,32 @@
+-- Сообщения всех присоединенных секций копируются в обычную таблицу. Отсоединенные секции не восстанавливаются.
+ALTER TABLE messages RENAME TO messages_partitioned;
+ALTER TABLE messages_partitioned RENAME CONSTRAINT messages_pkey TO messages_partitioned_pkey;
+
+CREATE TABLE messages (
+    id                bigint PRIMARY KEY DEFAULT nextval('messages_id_seq'),
+    content           varchar(256),
+    status            varchar(16) NOT NULL DEFAULT 'created',
+    created_at        timestamptz,
+    processed_at      timestamptz DEFAULT NULL,
+    result            text DEFAULT NULL,
+    error_reason      text DEFAULT NULL,
+    dispatch_attempts bigint NOT NULL DEFAULT 0,
+    dispatched_at     timestamptz DEFAULT NULL
+);
+
+INSERT INTO messages
+    (id, content, status, created_at, processed_at, result, error_reason, dispatch_attempts, dispatched_at)
+SELECT id, content, status, created_at, processed_at, result, error_reason, dispatch_attempts, dispatched_at
+FROM messages_partitioned;
+
+ALTER SEQUENCE messages_id_seq OWNED BY messages.id;
+
+DROP TABLE messages_partitioned;
+
+CREATE INDEX idx_messages_status ON messages (status);
+CREATE INDEX idx_messages_created_at_id ON messages (created_at, id);
+CREATE INDEX idx_messages_processed_at_id ON messages (processed_at, id);
+CREATE INDEX idx_messages_unprocessed ON messages (created_at, id)
+    WHERE status IN ('created', 'queued', 'processing');
+CREATE INDEX idx_messages_completed ON messages (created_at, id)
+    WHERE status IN ('processed', 'failed', 'cancelled');
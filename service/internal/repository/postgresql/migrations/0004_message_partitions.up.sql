@@ -0,0 +1,63 @@
+-- Таблица сообщений секционируется по месяцам created_at. Первичный ключ секционированной таблицы
+-- должен включать ключ секционирования, поэтому он состоит из id и created_at. Уникальность id
+-- обеспечивает последовательность messages_id_seq.
+--
+-- Существующие сообщения копируются в новую таблицу, поэтому на большой таблице миграция выполняется долго.
+ALTER TABLE messages RENAME TO messages_legacy;
+ALTER TABLE messages_legacy RENAME CONSTRAINT messages_pkey TO messages_legacy_pkey;
+
+CREATE TABLE messages (
+    id                bigint NOT NULL DEFAULT nextval('messages_id_seq'),
+    content           varchar(256),
+    status            varchar(16) NOT NULL DEFAULT 'created',
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    processed_at      timestamptz DEFAULT NULL,
+    result            text DEFAULT NULL,
+    error_reason      text DEFAULT NULL,
+    dispatch_attempts bigint NOT NULL DEFAULT 0,
+    dispatched_at     timestamptz DEFAULT NULL,
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+-- Секция по умолчанию принимает сообщения, для месяца которых секция не была создана заранее.
+CREATE TABLE messages_default PARTITION OF messages DEFAULT;
+
+-- Секции создаются для всех месяцев с существующими сообщениями и на три месяца вперед.
+-- Границы секций вычисляются в UTC независимо от часового пояса сессии.
+DO $$
+DECLARE
+    month timestamp;
+BEGIN
+    FOR month IN
+        SELECT generate_series(
+            date_trunc('month', COALESCE((SELECT min(created_at) FROM messages_legacy), now()) AT TIME ZONE 'UTC'),
+            date_trunc('month', now() AT TIME ZONE 'UTC') + interval '3 months',
+            interval '1 month'
+        )
+    LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF messages FOR VALUES FROM (%L) TO (%L)',
+            'messages_p' || to_char(month, 'YYYYMM'),
+            month AT TIME ZONE 'UTC',
+            (month + interval '1 month') AT TIME ZONE 'UTC'
+        );
+    END LOOP;
+END
+$$;
+
+INSERT INTO messages
+    (id, content, status, created_at, processed_at, result, error_reason, dispatch_attempts, dispatched_at)
+SELECT id, content, status, COALESCE(created_at, now()), processed_at, result, error_reason, dispatch_attempts, dispatched_at
+FROM messages_legacy;
+
+ALTER SEQUENCE messages_id_seq OWNED BY messages.id;
+
+DROP TABLE messages_legacy;
+
+CREATE INDEX idx_messages_status ON messages (status);
+CREATE INDEX idx_messages_created_at_id ON messages (created_at, id);
+CREATE INDEX idx_messages_processed_at_id ON messages (processed_at, id);
+CREATE INDEX idx_messages_unprocessed ON messages (created_at, id)
+    WHERE status IN ('created', 'queued', 'processing');
+CREATE INDEX idx_messages_completed ON messages (created_at, id)
+    WHERE status IN ('processed', 'failed', 'cancelled');
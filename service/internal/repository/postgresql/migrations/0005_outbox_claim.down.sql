@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE sent_at IS NULL;
+
+ALTER TABLE outbox_events
+    DROP COLUMN IF EXISTS failed_at,
+    DROP COLUMN IF EXISTS locked_until;
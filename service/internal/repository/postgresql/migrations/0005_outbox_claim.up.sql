@@ -0,0 +1,8 @@
+-- Событие захватывается репликой на время отправки, чтобы несколько реплик не отправляли его одновременно.
+-- Событие, которое не удалось отправить за максимальное количество попыток, больше не отправляется.
+ALTER TABLE outbox_events
+    ADD COLUMN IF NOT EXISTS locked_until timestamptz DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS failed_at    timestamptz DEFAULT NULL;
+
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE sent_at IS NULL AND failed_at IS NULL;
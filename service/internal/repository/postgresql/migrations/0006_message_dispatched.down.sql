@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_dispatched;
+CREATE INDEX idx_messages_unprocessed ON messages (created_at, id)
+    WHERE status IN ('created', 'queued', 'processing');
@@ -0,0 +1,9 @@
+-- Зависшими считаются только сообщения, событие старта обработки которых уже отправлено.
+-- Созданные сообщения отправляются из outbox и не должны отправляться повторно в обход него.
+UPDATE messages
+SET dispatched_at = created_at
+WHERE status IN ('queued', 'processing') AND dispatched_at IS NULL;
+
+DROP INDEX IF EXISTS idx_messages_unprocessed;
+CREATE INDEX idx_messages_dispatched ON messages (dispatched_at, id)
+    WHERE status IN ('queued', 'processing') AND dispatched_at IS NOT NULL;
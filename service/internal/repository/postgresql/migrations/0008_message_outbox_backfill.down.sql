@@ -0,0 +1 @@
+-- Созданные события не отличаются от остальных событий outbox и не удаляются.
@@ -0,0 +1,13 @@
+-- Сообщения, созданные до появления outbox, остались в статусе created без события старта обработки,
+-- поэтому они никогда не будут отправлены обработчику. Для них создаются события, которые отправит relay.
+-- Уже отправленное ранее сообщение может быть отправлено повторно, повторное завершение обработки игнорируется.
+INSERT INTO outbox_events (type, key, payload, created_at)
+SELECT 'start-processing-message', gen_random_uuid()::text, jsonb_build_object('id', m.id, 'content', m.content), now()
+FROM messages m
+WHERE m.status = 'created'
+  AND NOT EXISTS (
+    SELECT 1
+    FROM outbox_events e
+    WHERE e.type = 'start-processing-message' AND e.payload->>'id' = m.id::text
+  )
+ORDER BY m.created_at, m.id;
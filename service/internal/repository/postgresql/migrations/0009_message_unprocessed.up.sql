@@ -0,0 +1,5 @@
+-- Индекс необработанных сообщений, удаленный в 0006, используется при отборе сообщений по статусу
+-- с сортировкой по дате создания. Условие индекса следует из условия messageStatuses для любого
+-- набора статусов created, queued и processing.
+CREATE INDEX IF NOT EXISTS idx_messages_unprocessed ON messages (created_at, id)
+    WHERE status IN ('created', 'queued', 'processing');
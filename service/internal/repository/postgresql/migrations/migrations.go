@@ -0,0 +1,12 @@
+// Package migrations содержит версионированные SQL-миграции схемы базы данных.
+//
+// Каждая миграция состоит из пары файлов <версия>_<название>.up.sql и <версия>_<название>.down.sql.
+// Миграции применяются в порядке возрастания версии.
+package migrations
+
+import "embed"
+
+// FS содержит файлы миграций.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,166 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/pkg/tracing"
+)
+
+// ClaimOutboxEvents захватывает неотправленные события в порядке их создания на время lease.
+//
+// Строки блокируются через FOR UPDATE SKIP LOCKED, поэтому одновременно работающие реплики
+// захватывают разные события. Захваченное событие не будет захвачено снова до истечения lease.
+func (r *Repository) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	var outboxEvents []models.OutboxEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := claimableOutboxEvents(tx, limit).
+			Find(&outboxEvents).
+			Error
+		if err != nil || len(outboxEvents) == 0 {
+			return err
+		}
+
+		lockedUntil := time.Now().Add(lease)
+		err = tx.
+			Model(&outboxEvents).
+			Update("locked_until", lockedUntil).
+			Error
+		if err != nil {
+			return err
+		}
+
+		for i := range outboxEvents {
+			outboxEvents[i].LockedUntil = &lockedUntil
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return outboxEvents, nil
+}
+
+// MarkOutboxEventsSent отмечает события как отправленные.
+func (r *Repository) MarkOutboxEventsSent(ctx context.Context, ids []uint64) error {
+	tx := r.db.
+		WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id IN ?", ids).
+		Update("sent_at", gorm.Expr("now()"))
+
+	return tx.Error
+}
+
+// MarkOutboxEventFailed фиксирует неудачную попытку отправки события и освобождает его.
+// Если dead установлен, событие больше не отправляется, а сообщение, обработку которого оно должно было начать,
+// в той же транзакции завершается с ошибкой, чтобы оно не осталось в статусе created навсегда.
+func (r *Repository) MarkOutboxEventFailed(ctx context.Context, id uint64, reason string, dead bool) error {
+	values := map[string]any{
+		"attempts":     gorm.Expr("attempts + 1"),
+		"last_error":   reason,
+		"locked_until": nil,
+	}
+	if !dead {
+		return r.db.
+			WithContext(ctx).
+			Model(&models.OutboxEvent{ID: id}).
+			Updates(values).
+			Error
+	}
+
+	values["failed_at"] = gorm.Expr("now()")
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		e := models.OutboxEvent{ID: id}
+		res := tx.
+			Model(&e).
+			Clauses(clause.Returning{}).
+			Updates(values)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+
+		return failUndispatchedMessage(tx, e, reason)
+	})
+}
+
+// failUndispatchedMessage завершает с ошибкой созданное сообщение, событие старта обработки которого
+// больше не будет отправлено. События других типов и сообщения в других статусах не изменяются.
+func failUndispatchedMessage(tx *gorm.DB, e models.OutboxEvent, reason string) error {
+	if e.Type != events.TypeStartProcessingMessage {
+		return nil
+	}
+
+	var payload events.StartProcessingMessage
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal event payload: %w", err)
+	}
+
+	errorReason := "failed to dispatch start processing event: " + reason
+
+	return tx.
+		Model(&models.Message{ID: payload.ID}).
+		Scopes(messageStatuses([]string{models.MessageStatusCreated})).
+		Updates(map[string]any{
+			"status":       models.MessageStatusFailed,
+			"processed_at": time.Now(),
+			"error_reason": errorReason,
+		}).
+		Error
+}
+
+// deletePendingMessageEvents удаляет неотправленные события старта обработки сообщения в транзакции tx.
+//
+// Событие удаляется, даже если оно захвачено для отправки. Если отправка уже началась, событие
+// может дойти до обработчика, но отметка об отправке не изменит ни событие, ни отмененное сообщение.
+func deletePendingMessageEvents(tx *gorm.DB, messageID uint64) error {
+	return tx.
+		Scopes(outboxEventPending).
+		Where("type = ? AND payload->>'id' = ?", events.TypeStartProcessingMessage, strconv.FormatUint(messageID, 10)).
+		Delete(&models.OutboxEvent{}).
+		Error
+}
+
+// newOutboxEvent создает событие outbox с сериализованным содержимым и контекстом трассировки.
+func newOutboxEvent(ctx context.Context, eventType string, payload any) (models.OutboxEvent, error) {
+	pBytes, err := json.Marshal(payload)
+	if err != nil {
+		return models.OutboxEvent{}, fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	return models.OutboxEvent{
+		Type:    eventType,
+		Key:     uuid.New().String(),
+		Payload: pBytes,
+		Headers: tracing.InjectMap(ctx),
+	}, nil
+}
+
+// claimableOutboxEvents возвращает запрос очередного пакета событий, доступных для захвата.
+func claimableOutboxEvents(db *gorm.DB, limit int) *gorm.DB {
+	return db.
+		Scopes(outboxEventPending).
+		Where("locked_until IS NULL OR locked_until < now()").
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Order("id").
+		Limit(limit)
+}
+
+// outboxEventPending фильтрует события, которые еще нужно отправить.
+//
+// Условие совпадает с условием частичного индекса idx_outbox_events_pending.
+func outboxEventPending(db *gorm.DB) *gorm.DB {
+	return db.Where("sent_at IS NULL AND failed_at IS NULL")
+}
@@ -0,0 +1,117 @@
+package postgresql
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// messageEvent возвращает событие старта обработки сообщения.
+func messageEvent(t *testing.T, r *Repository, messageID uint64) models.OutboxEvent {
+	t.Helper()
+
+	var e models.OutboxEvent
+	err := r.db.
+		Where("type = ? AND payload->>'id' = ?", events.TypeStartProcessingMessage, strconv.FormatUint(messageID, 10)).
+		First(&e).
+		Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestMarkOutboxEventFailed(t *testing.T) {
+	r := &Repository{db: openTestDB(t)}
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		dead       bool
+		wantStatus string
+	}{
+		{name: "attempts left", dead: false, wantStatus: models.MessageStatusCreated},
+		{name: "dead", dead: true, wantStatus: models.MessageStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, _, err := r.SaveMessage(ctx, models.Message{Content: "content", CreatedAt: time.Now()}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := messageEvent(t, r, id)
+			if err := r.MarkOutboxEventFailed(ctx, e.ID, "broker is unavailable", tt.dead); err != nil {
+				t.Fatal(err)
+			}
+
+			e = messageEvent(t, r, id)
+			if e.Attempts != 1 || (e.FailedAt != nil) != tt.dead {
+				t.Errorf("event attempts = %d, failed_at = %v, want 1 attempt and dead %v", e.Attempts, e.FailedAt, tt.dead)
+			}
+
+			m, err := r.Message(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Status != tt.wantStatus {
+				t.Errorf("message status = %s, want %s", m.Status, tt.wantStatus)
+			}
+			if tt.dead && (m.ErrorReason == nil || m.ProcessedAt == nil) {
+				t.Errorf("error reason = %v, processed at = %v, want both set", m.ErrorReason, m.ProcessedAt)
+			}
+		})
+	}
+}
+
+func TestCancelClaimedMessage(t *testing.T) {
+	r := &Repository{db: openTestDB(t)}
+	ctx := context.Background()
+
+	id, _, err := r.SaveMessage(ctx, models.Message{Content: "content", CreatedAt: time.Now()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := messageEvent(t, r, id)
+	claimed, err := r.ClaimOutboxEvents(ctx, 1000, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(claimed, func(c models.OutboxEvent) bool { return c.ID == e.ID }) {
+		t.Fatalf("event %d is not claimed", e.ID)
+	}
+
+	err = r.UpdateMessageStatus(ctx, id, models.MessageStatusCreated, models.MessageStatusUpdate{Status: models.MessageStatusCancelled})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Отметка об отправке захваченного события не восстанавливает удаленное событие.
+	if err := r.MarkOutboxEventsSent(ctx, []uint64{e.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+	if err := r.db.Model(&models.OutboxEvent{}).Where("id = ?", e.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("claimed event of cancelled message is not deleted")
+	}
+
+	m, err := r.Message(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != models.MessageStatusCancelled {
+		t.Errorf("message status = %s, want %s", m.Status, models.MessageStatusCancelled)
+	}
+}
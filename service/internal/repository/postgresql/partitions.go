@@ -0,0 +1,182 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// partitionLockID это ключ advisory-блокировки, под которой изменяются секции таблицы сообщений.
+// Блокировка не дает нескольким репликам изменять секции одновременно.
+const partitionLockID int64 = 4_815_162_343
+
+// messagePartitionPrefix это префикс имени месячной секции таблицы сообщений.
+// За ним следует месяц в формате YYYYMM.
+const messagePartitionPrefix = "messages_p"
+
+// messagePartitionLayout это формат месяца в имени секции таблицы сообщений.
+const messagePartitionLayout = "200601"
+
+// CreateMessagePartitions создает месячные секции таблицы сообщений с месяца from на count месяцев
+// и возвращает имена созданных секций. Существующие секции пропускаются.
+//
+// Создание секции завершается ошибкой, если в секции по умолчанию уже есть сообщения за ее месяц.
+func (r *Repository) CreateMessagePartitions(ctx context.Context, from time.Time, count int) ([]string, error) {
+	var created []string
+	err := r.withPartitionLock(ctx, func(tx *gorm.DB) error {
+		existing, err := messagePartitions(tx)
+		if err != nil {
+			return err
+		}
+
+		month := monthStart(from)
+		for i := 0; i < count; i++ {
+			start, end := month.AddDate(0, i, 0), month.AddDate(0, i+1, 0)
+			name := messagePartitionName(start)
+			if _, ok := existing[name]; ok {
+				continue
+			}
+
+			err := tx.Exec(fmt.Sprintf(
+				"CREATE TABLE %s PARTITION OF messages FOR VALUES FROM ('%s') TO ('%s')",
+				name, start.Format(time.RFC3339), end.Format(time.RFC3339),
+			)).Error
+			if err != nil {
+				return fmt.Errorf("failed to create partition %s: %w", name, err)
+			}
+
+			created = append(created, name)
+		}
+
+		return nil
+	})
+
+	return created, err
+}
+
+// DetachMessagePartitions отсоединяет от таблицы сообщений месячные секции, которые целиком предшествуют before,
+// и возвращает имена отсоединенных и пропущенных секций. Если drop установлен, отсоединенные секции удаляются.
+//
+// Секции с необработанными сообщениями пропускаются, чтобы не потерять результаты их обработки.
+// Ключи идемпотентности сообщений отсоединяемой секции удаляются вместе с ней.
+func (r *Repository) DetachMessagePartitions(ctx context.Context, before time.Time, drop bool) ([]string, []string, error) {
+	var detached, skipped []string
+	err := r.withPartitionLock(ctx, func(tx *gorm.DB) error {
+		existing, err := messagePartitions(tx)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(existing))
+		for name := range existing {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			if existing[name].AddDate(0, 1, 0).After(before) {
+				continue
+			}
+
+			var unprocessed bool
+			err := tx.
+				Raw(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE status IN ('created', 'queued', 'processing'))", name)).
+				Scan(&unprocessed).
+				Error
+			if err != nil {
+				return fmt.Errorf("failed to check partition %s: %w", name, err)
+			}
+
+			if unprocessed {
+				skipped = append(skipped, name)
+				continue
+			}
+
+			err = tx.Exec(fmt.Sprintf(
+				"DELETE FROM idempotency_keys USING %[1]s WHERE idempotency_keys.message_id = %[1]s.id", name,
+			)).Error
+			if err != nil {
+				return fmt.Errorf("failed to delete idempotency keys of partition %s: %w", name, err)
+			}
+
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE messages DETACH PARTITION %s", name)).Error; err != nil {
+				return fmt.Errorf("failed to detach partition %s: %w", name, err)
+			}
+
+			if drop {
+				if err := tx.Exec(fmt.Sprintf("DROP TABLE %s", name)).Error; err != nil {
+					return fmt.Errorf("failed to drop partition %s: %w", name, err)
+				}
+			}
+
+			detached = append(detached, name)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return detached, skipped, nil
+}
+
+// withPartitionLock выполняет fn в транзакции под advisory-блокировкой секций.
+func (r *Repository) withPartitionLock(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", partitionLockID).Error; err != nil {
+			return fmt.Errorf("failed to acquire partition lock: %w", err)
+		}
+
+		return fn(tx)
+	})
+}
+
+// messagePartitions возвращает месяцы присоединенных месячных секций таблицы сообщений по их именам.
+// Секция по умолчанию и секции с именами другого формата не возвращаются.
+func messagePartitions(tx *gorm.DB) (map[string]time.Time, error) {
+	var names []string
+	err := tx.
+		Raw(`SELECT c.relname
+FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+WHERE i.inhparent = 'messages'::regclass`).
+		Scan(&names).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make(map[string]time.Time, len(names))
+	for _, name := range names {
+		suffix, ok := strings.CutPrefix(name, messagePartitionPrefix)
+		if !ok {
+			continue
+		}
+
+		month, err := time.ParseInLocation(messagePartitionLayout, suffix, time.UTC)
+		if err != nil {
+			continue
+		}
+
+		partitions[name] = month
+	}
+
+	return partitions, nil
+}
+
+// messagePartitionName возвращает имя секции таблицы сообщений за месяц month.
+func messagePartitionName(month time.Time) string {
+	return messagePartitionPrefix + month.Format(messagePartitionLayout)
+}
+
+// monthStart возвращает начало месяца t в UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -1,53 +1,248 @@
 package postgresql
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"github.com/sedonn/message-service/internal/config"
 	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/event/kafka/producer"
 	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
 	"github.com/sedonn/message-service/internal/services/message"
 )
 
 // Repository содержит методы взаимодействия с базой данных PostgreSQL.
+//
+// Запись выполняется на основной базе данных. Чтение списков, статистики и отдельных сообщений
+// распределяется между работоспособными репликами, если они настроены.
 type Repository struct {
-	db *gorm.DB
+	log      *slog.Logger
+	cfg      *config.DBReplicasConfig
+	db       *gorm.DB
+	replicas []*replica
+	next     *atomic.Uint64
+	wg       *sync.WaitGroup
 }
 
 var _ message.MessageProvider = (*Repository)(nil)
 var _ message.MessageSaver = (*Repository)(nil)
+var _ message.MessageUpdater = (*Repository)(nil)
+var _ message.StuckMessageProvider = (*Repository)(nil)
+var _ message.MessagePurger = (*Repository)(nil)
+var _ message.MessagePartitionManager = (*Repository)(nil)
+var _ producer.OutboxProvider = (*Repository)(nil)
 
 // New создает новый объект репозитория.
-func New(cfg *config.Config) (*Repository, error) {
-	dsn := makeDSN(&cfg.DB)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+func New(log *slog.Logger, cfg *config.Config, m *metrics.DB) (*Repository, error) {
+	db, err := open(&cfg.DB, cfg.Env, m)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := newMigrator(sqlDB)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DB.AutoMigrate {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
+	} else if err := migrator.EnsureUpToDate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	replicas, err := openReplicas(cfg, m)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repository{
+		log:      log,
+		cfg:      &cfg.DB.Replicas,
+		db:       db,
+		replicas: replicas,
+		next:     &atomic.Uint64{},
+		wg:       &sync.WaitGroup{},
+	}
+	r.checkReplicas(context.Background())
+
+	return r, nil
+}
+
+// open подключается к базе данных и регистрирует плагины метрик и трассировки.
+func open(cfg *config.DBConfig, env string, m *metrics.DB) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(makeDSN(cfg)), &gorm.Config{
 		SkipDefaultTransaction: true,
 		PrepareStmt:            true,
-		Logger:                 logger.NewGORMLogger(cfg.Env),
+		Logger:                 logger.NewGORMLogger(env),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&models.Message{}); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	if err := configurePool(db, &cfg.Pool); err != nil {
+		return nil, fmt.Errorf("failed to configure connection pool: %w", err)
+	}
+
+	if err := db.Use(&metricsPlugin{m: m}); err != nil {
+		return nil, fmt.Errorf("failed to register metrics plugin: %w", err)
+	}
+
+	if err := db.Use(&tracingPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
 	}
 
-	return &Repository{db: db}, nil
+	return db, nil
 }
 
-// paginate обеспечивает постраничную навигацию в результатах запроса.
-func paginate(id, size uint) func(*gorm.DB) *gorm.DB {
+// Check проверяет подключение к базе данных.
+func (r *Repository) Check(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+// paginate обеспечивает постраничную навигацию в результатах запроса по курсору.
+//
+// Сообщения упорядочиваются по полю сортировки и ID. Необработанные сообщения при сортировке по дате обработки
+// всегда идут в конце. Запрашивается на одно сообщение больше лимита, чтобы определить наличие следующей страницы.
+func paginate(sort models.MessageSort, p models.PageRequest) func(*gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Limit(int(size)).Offset(int(id * size))
+		cmp, order := ">", "ASC"
+		if sort.Order == models.SortOrderDesc {
+			cmp, order = "<", "DESC"
+		}
+
+		switch sort.Field {
+		case models.SortByID:
+			if p.After != nil {
+				db = db.Where("id "+cmp+" ?", p.After.ID)
+			}
+
+			db = db.Order("id " + order)
+		case models.SortByProcessedAt:
+			if p.After != nil {
+				if p.After.Value != nil {
+					db = db.Where("((processed_at, id) "+cmp+" (?, ?) OR processed_at IS NULL)", *p.After.Value, p.After.ID)
+				} else {
+					db = db.Where("processed_at IS NULL AND id "+cmp+" ?", p.After.ID)
+				}
+			}
+
+			db = db.Order("processed_at " + order + " NULLS LAST, id " + order)
+		default:
+			if p.After != nil {
+				db = db.Where("(created_at, id) "+cmp+" (?, ?)", *p.After.Value, p.After.ID)
+			}
+
+			db = db.Order("created_at " + order + ", id " + order)
+		}
+
+		return db.Limit(p.Limit + 1)
+	}
+}
+
+// newMessagePage создает страницу из результата запроса, выполненного с paginate.
+func newMessagePage(messages []models.Message, sort models.MessageSort, limit int) models.MessagePage {
+	if len(messages) <= limit {
+		return models.MessagePage{Items: messages}
+	}
+
+	messages = messages[:limit]
+	last := messages[len(messages)-1]
+
+	next := &models.Cursor{Sort: sort, ID: last.ID}
+	switch sort.Field {
+	case models.SortByCreatedAt:
+		next.Value = &last.CreatedAt
+	case models.SortByProcessedAt:
+		next.Value = last.ProcessedAt
+	}
+
+	return models.MessagePage{
+		Items: messages,
+		Next:  next,
+	}
+}
+
+// timeBetween фильтрует записи, у которых значение столбца попадает в полуинтервал [from, to).
+func timeBetween(column string, from, to *time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if from != nil {
+			db = db.Where(column+" >= ?", *from)
+		}
+
+		if to != nil {
+			db = db.Where(column+" < ?", *to)
+		}
+
+		return db
 	}
 }
 
 // makeDSN создает строку подключения к базе данных на основе текущей конфигурации.
+//
+// DSN собирается в виде URL, поэтому спецсимволы в учетных данных, адресе и имени базы данных экранируются.
 func makeDSN(cfg *config.DBConfig) string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		cfg.Host, cfg.User, cfg.Password, cfg.Database, cfg.Port)
+	query := url.Values{}
+	query.Set("sslmode", cfg.SSLMode)
+	setIfNotEmpty(query, "sslrootcert", cfg.SSLRootCert)
+	setIfNotEmpty(query, "sslcert", cfg.SSLCert)
+	setIfNotEmpty(query, "sslkey", cfg.SSLKey)
+	setIfNotEmpty(query, "application_name", cfg.ApplicationName)
+	if cfg.StatementTimeout > 0 {
+		query.Set("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     "/" + cfg.Database,
+		RawQuery: query.Encode(),
+	}
+
+	return dsn.String()
+}
+
+// setIfNotEmpty устанавливает параметр запроса, если его значение не пустое.
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// configurePool применяет настройки пула подключений к базе данных.
+func configurePool(db *gorm.DB, cfg *config.DBPoolConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return nil
 }
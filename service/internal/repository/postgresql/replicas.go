@@ -0,0 +1,226 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/pkg/consistency"
+	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+)
+
+// replicationStatusQuery возвращает признак режима восстановления, состояние приемника журнала и отставание
+// репликации в секундах. Реплика, которая воспроизвела весь полученный журнал, не отстает, даже если
+// на основной базе данных давно не было записи. Если отставание определить нельзя, оно равно NULL.
+//
+// Состояние приемника равно stopped, если приемник не запущен, и unknown, если у пользователя нет прав
+// на просмотр pg_stat_wal_receiver.
+const replicationStatusQuery = `
+SELECT
+    pg_is_in_recovery() AS in_recovery,
+    COALESCE((SELECT COALESCE(status, 'unknown') FROM pg_stat_wal_receiver), 'stopped') AS receiver_status,
+    CASE
+        WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+        ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+    END AS lag`
+
+// Состояния приемника журнала, которые возвращает replicationStatusQuery.
+const (
+	// walReceiverStreaming это состояние приемника, который получает журнал с основной базы данных.
+	walReceiverStreaming = "streaming"
+	// walReceiverUnknown это состояние приемника, которое пользователю не разрешено просматривать.
+	walReceiverUnknown = "unknown"
+)
+
+// replicationStatus это результат replicationStatusQuery.
+type replicationStatus struct {
+	InRecovery bool
+	// ReceiverStatus это состояние приемника журнала из pg_stat_wal_receiver.
+	ReceiverStatus string
+	// Lag это отставание репликации в секундах. Пусто, если отставание неизвестно.
+	Lag *float64
+}
+
+// replica это реплика базы данных и результат ее последней проверки.
+type replica struct {
+	host    string
+	db      *gorm.DB
+	healthy *atomic.Bool
+}
+
+// openReplicas подключается к репликам базы данных. Параметры подключения, кроме адреса,
+// совпадают с параметрами основной базы данных.
+func openReplicas(cfg *config.Config, m *metrics.DB) ([]*replica, error) {
+	replicas := make([]*replica, 0, len(cfg.DB.Replicas.Hosts))
+	for _, hostport := range cfg.DB.Replicas.Hosts {
+		host, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replica address %s: %w", hostport, err)
+		}
+
+		replicaCfg := cfg.DB
+		replicaCfg.Host = host
+		if replicaCfg.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid replica port %s: %w", hostport, err)
+		}
+
+		db, err := open(&replicaCfg, cfg.Env, m)
+		if err != nil {
+			return nil, fmt.Errorf("replica %s: %w", hostport, err)
+		}
+
+		replicas = append(replicas, &replica{host: hostport, db: db, healthy: &atomic.Bool{}})
+	}
+
+	return replicas, nil
+}
+
+// Run запускает фоновую проверку реплик. Проверка прекращается при отмене контекста.
+func (r *Repository) Run(ctx context.Context) {
+	const op = "postgresql.Run"
+	log := r.log.With(slog.String("op", op))
+
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.cfg.HealthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.checkReplicas(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Info("replicas health check start working", slog.Int("replicas_count", len(r.replicas)))
+}
+
+// Stop ожидает завершения текущей проверки реплик и закрывает подключения к ним.
+// После остановки чтение выполняется на основной базе данных.
+func (r *Repository) Stop() {
+	const op = "postgresql.Stop"
+	log := r.log.With(slog.String("op", op))
+
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	log.Info("stopping replicas health check")
+	r.wg.Wait()
+
+	for _, rp := range r.replicas {
+		rp.healthy.Store(false)
+
+		sqlDB, err := rp.db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			log.Error("failed to close replica connections", slog.String("replica", rp.host), logger.StringError(err))
+		}
+	}
+
+	log.Info("replicas health check stopped")
+}
+
+// reader возвращает подключение для чтения: очередную работоспособную реплику или основную базу данных,
+// если работоспособных реплик нет или контекст требует чтения с основной базы данных.
+func (r *Repository) reader(ctx context.Context) *gorm.DB {
+	if len(r.replicas) == 0 || consistency.Primary(ctx) {
+		return r.db
+	}
+
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		rp := r.replicas[(start+i)%n]
+		if rp.healthy.Load() {
+			return rp.db
+		}
+	}
+
+	return r.db
+}
+
+// checkReplicas проверяет доступность, режим восстановления и отставание реплик и отмечает их работоспособными или нет.
+func (r *Repository) checkReplicas(ctx context.Context) {
+	const op = "postgresql.checkReplicas"
+	log := r.log.With(slog.String("op", op))
+
+	for _, rp := range r.replicas {
+		log := log.With(slog.String("replica", rp.host))
+
+		err := r.checkReplica(ctx, rp)
+		healthy := err == nil
+		if rp.healthy.Swap(healthy) == healthy {
+			continue
+		}
+
+		if healthy {
+			log.Info("replica is healthy, reads are routed to it")
+		} else {
+			log.Warn("replica is unhealthy, reads are routed to other replicas or primary", logger.StringError(err))
+		}
+	}
+}
+
+// checkReplica возвращает ошибку, если реплика недоступна, не находится в режиме восстановления,
+// не получает журнал с основной базы данных, ее отставание неизвестно или больше допустимого.
+func (r *Repository) checkReplica(ctx context.Context, rp *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	var s replicationStatus
+	if err := rp.db.WithContext(ctx).Raw(replicationStatusQuery).Scan(&s).Error; err != nil {
+		return err
+	}
+
+	return s.check(r.cfg.MaxLag)
+}
+
+// check возвращает ошибку, если узел не является репликой, не получает журнал с основной базы данных,
+// отставание репликации неизвестно или превышает maxLag. Нулевое maxLag не ограничивает отставание.
+//
+// Реплика с отключенным приемником журнала воспроизвела весь полученный журнал и выглядит не отстающей,
+// поэтому она считается неработоспособной независимо от отставания.
+func (s replicationStatus) check(maxLag time.Duration) error {
+	if !s.InRecovery {
+		return errors.New("node is not a replica")
+	}
+
+	if s.ReceiverStatus == walReceiverUnknown {
+		return errors.New("wal receiver status is unknown, pg_read_all_stats privileges are required")
+	}
+
+	if s.ReceiverStatus != walReceiverStreaming {
+		return fmt.Errorf("wal receiver is %s, not %s", s.ReceiverStatus, walReceiverStreaming)
+	}
+
+	if s.Lag == nil {
+		return errors.New("replication lag is unknown")
+	}
+
+	if maxLag > 0 && *s.Lag > maxLag.Seconds() {
+		return fmt.Errorf("replication lag %.1fs exceeds %s", *s.Lag, maxLag)
+	}
+
+	return nil
+}
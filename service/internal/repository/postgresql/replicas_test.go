@@ -0,0 +1,50 @@
+package postgresql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplicationStatusCheck(t *testing.T) {
+	lag := func(seconds float64) *float64 { return &seconds }
+
+	tests := []struct {
+		name    string
+		status  replicationStatus
+		maxLag  time.Duration
+		wantErr bool
+	}{
+		{name: "no lag", status: replicationStatus{InRecovery: true, ReceiverStatus: walReceiverStreaming, Lag: lag(0)}, maxLag: time.Second},
+		{name: "lag within limit", status: replicationStatus{InRecovery: true, ReceiverStatus: walReceiverStreaming, Lag: lag(0.5)}, maxLag: time.Second},
+		{name: "lag exceeds limit", status: replicationStatus{InRecovery: true, ReceiverStatus: walReceiverStreaming, Lag: lag(2)}, maxLag: time.Second, wantErr: true},
+		{name: "lag is not limited", status: replicationStatus{InRecovery: true, ReceiverStatus: walReceiverStreaming, Lag: lag(3600)}},
+		{name: "lag is unknown", status: replicationStatus{InRecovery: true, ReceiverStatus: walReceiverStreaming}, maxLag: time.Second, wantErr: true},
+		{name: "lag is unknown and not limited", status: replicationStatus{InRecovery: true, ReceiverStatus: walReceiverStreaming}, wantErr: true},
+		{name: "node is not a replica", status: replicationStatus{Lag: lag(0)}, maxLag: time.Second, wantErr: true},
+		{
+			name:    "wal receiver is stopped",
+			status:  replicationStatus{InRecovery: true, ReceiverStatus: "stopped", Lag: lag(0)},
+			maxLag:  time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "wal receiver is waiting",
+			status:  replicationStatus{InRecovery: true, ReceiverStatus: "waiting", Lag: lag(0)},
+			wantErr: true,
+		},
+		{
+			name:    "wal receiver status is unknown",
+			status:  replicationStatus{InRecovery: true, ReceiverStatus: walReceiverUnknown, Lag: lag(0)},
+			maxLag:  time.Second,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.status.check(tt.maxLag); (err != nil) != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
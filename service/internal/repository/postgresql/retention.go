@@ -0,0 +1,97 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+)
+
+// Запросы очистки обрабатывают строки пакетами и пропускают заблокированные строки, поэтому не блокируют
+// таблицы надолго. Условие отбора сообщений записано без параметров и совпадает с условием частичного
+// индекса idx_messages_completed.
+const (
+	// deleteCompletedMessagesQuery удаляет пакет завершенных сообщений, созданных раньше заданного времени.
+	deleteCompletedMessagesQuery = `
+WITH batch AS (
+    SELECT id FROM messages
+    WHERE status IN ('processed', 'failed', 'cancelled') AND created_at < @before
+    ORDER BY created_at, id
+    LIMIT @limit
+    FOR UPDATE SKIP LOCKED
+)
+DELETE FROM messages USING batch WHERE messages.id = batch.id`
+
+	// archiveCompletedMessagesQuery переносит пакет завершенных сообщений, созданных раньше заданного времени,
+	// в архивную таблицу.
+	archiveCompletedMessagesQuery = `
+WITH batch AS (
+    SELECT id FROM messages
+    WHERE status IN ('processed', 'failed', 'cancelled') AND created_at < @before
+    ORDER BY created_at, id
+    LIMIT @limit
+    FOR UPDATE SKIP LOCKED
+), moved AS (
+    DELETE FROM messages USING batch WHERE messages.id = batch.id
+    RETURNING messages.*
+)
+INSERT INTO messages_archive
+    (id, content, status, created_at, processed_at, result, error_reason, dispatch_attempts, dispatched_at)
+SELECT id, content, status, created_at, processed_at, result, error_reason, dispatch_attempts, dispatched_at
+FROM moved`
+
+	// deleteSentOutboxEventsQuery удаляет пакет событий outbox, отправленных раньше заданного времени.
+	// Условие отбора совпадает с условием частичного индекса idx_outbox_events_sent_at.
+	deleteSentOutboxEventsQuery = `
+WITH batch AS (
+    SELECT id FROM outbox_events
+    WHERE sent_at IS NOT NULL AND sent_at < @before
+    ORDER BY sent_at
+    LIMIT @limit
+    FOR UPDATE SKIP LOCKED
+)
+DELETE FROM outbox_events USING batch WHERE outbox_events.id = batch.id`
+
+	// deleteExpiredIdempotencyKeysQuery удаляет пакет истекших ключей идемпотентности.
+	deleteExpiredIdempotencyKeysQuery = `
+WITH batch AS (
+    SELECT key FROM idempotency_keys
+    WHERE expires_at <= now()
+    ORDER BY expires_at
+    LIMIT @limit
+    FOR UPDATE SKIP LOCKED
+)
+DELETE FROM idempotency_keys USING batch WHERE idempotency_keys.key = batch.key`
+)
+
+// PurgeMessages удаляет не больше limit завершенных сообщений, созданных раньше before, и возвращает их количество.
+// Если archive установлен, сообщения переносятся в архивную таблицу.
+func (r *Repository) PurgeMessages(ctx context.Context, before time.Time, limit int, archive bool) (int64, error) {
+	query := deleteCompletedMessagesQuery
+	if archive {
+		query = archiveCompletedMessagesQuery
+	}
+
+	tx := r.db.
+		WithContext(ctx).
+		Exec(query, map[string]any{"before": before, "limit": limit})
+
+	return tx.RowsAffected, tx.Error
+}
+
+// PurgeOutboxEvents удаляет не больше limit событий outbox, отправленных раньше before, и возвращает их количество.
+// Неотправленные события не удаляются.
+func (r *Repository) PurgeOutboxEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tx := r.db.
+		WithContext(ctx).
+		Exec(deleteSentOutboxEventsQuery, map[string]any{"before": before, "limit": limit})
+
+	return tx.RowsAffected, tx.Error
+}
+
+// PurgeIdempotencyKeys удаляет не больше limit истекших ключей идемпотентности и возвращает их количество.
+func (r *Repository) PurgeIdempotencyKeys(ctx context.Context, limit int) (int64, error) {
+	tx := r.db.
+		WithContext(ctx).
+		Exec(deleteExpiredIdempotencyKeysQuery, map[string]any{"limit": limit})
+
+	return tx.RowsAffected, tx.Error
+}
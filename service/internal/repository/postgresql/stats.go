@@ -0,0 +1,89 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// messageCounts это результат запроса количества сообщений по статусам.
+type messageCounts struct {
+	Total       uint64
+	Processed   uint64
+	Failed      uint64
+	Cancelled   uint64
+	Unprocessed uint64
+}
+
+// latencyPercentiles это результат запроса перцентилей задержки обработки в секундах.
+type latencyPercentiles struct {
+	Count uint64
+	P50   *float64
+	P90   *float64
+	P95   *float64
+	P99   *float64
+}
+
+// MessageStats возвращает статистику обработки сообщений.
+// Перцентили задержки считаются по сообщениям, обработанным после since.
+func (r *Repository) MessageStats(ctx context.Context, since time.Time) (models.MessageStats, error) {
+	db := r.reader(ctx)
+
+	var counts messageCounts
+	tx := db.
+		WithContext(ctx).
+		Model(&models.Message{}).
+		Select(`count(*) AS total,
+			count(*) FILTER (WHERE status = 'processed') AS processed,
+			count(*) FILTER (WHERE status = 'failed') AS failed,
+			count(*) FILTER (WHERE status = 'cancelled') AS cancelled,
+			count(*) FILTER (WHERE status IN ('created', 'queued', 'processing')) AS unprocessed`).
+		Scan(&counts)
+
+	if tx.Error != nil {
+		return models.MessageStats{}, tx.Error
+	}
+
+	var latency latencyPercentiles
+	tx = db.
+		WithContext(ctx).
+		Model(&models.Message{}).
+		Select(`count(*) AS count,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY extract(epoch FROM processed_at - created_at)) AS p50,
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY extract(epoch FROM processed_at - created_at)) AS p90,
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY extract(epoch FROM processed_at - created_at)) AS p95,
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY extract(epoch FROM processed_at - created_at)) AS p99`).
+		Scopes(messageProcessed, messageProcessedBetween(&since, nil)).
+		Scan(&latency)
+
+	if tx.Error != nil {
+		return models.MessageStats{}, tx.Error
+	}
+
+	return models.MessageStats{
+		Total:       counts.Total,
+		Processed:   counts.Processed,
+		Failed:      counts.Failed,
+		Cancelled:   counts.Cancelled,
+		Unprocessed: counts.Unprocessed,
+		Latency: models.LatencyStats{
+			Count: latency.Count,
+			P50:   secondsToDuration(latency.P50),
+			P90:   secondsToDuration(latency.P90),
+			P95:   secondsToDuration(latency.P95),
+			P99:   secondsToDuration(latency.P99),
+		},
+	}, nil
+}
+
+// secondsToDuration преобразует количество секунд в time.Duration.
+func secondsToDuration(s *float64) *time.Duration {
+	if s == nil {
+		return nil
+	}
+
+	d := time.Duration(*s * float64(time.Second))
+
+	return &d
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Статусы проверок.
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+// Checker описывает поведение зависимости, готовность которой можно проверить.
+type Checker interface {
+	// Check возвращает ошибку, если зависимость не готова к работе.
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc позволяет использовать функцию как Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check реализует метод Checker.
+func (f CheckerFunc) Check(ctx context.Context) error { return f(ctx) }
+
+type response struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BindTo подключает хендлеры проверки жизнеспособности и готовности микросервиса.
+//
+// /healthz отвечает, пока процесс работает. /readyz проверяет все зависимости с ограничением по времени
+// и возвращает 503, если хотя бы одна из них не готова.
+func BindTo(router *gin.Engine, timeout time.Duration, checkers map[string]Checker) {
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, response{Status: statusOK})
+	})
+
+	router.GET("/readyz", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, timeout)
+		defer cancel()
+
+		resp := check(ctx, checkers)
+		if resp.Status != statusOK {
+			c.JSON(http.StatusServiceUnavailable, resp)
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+// check параллельно выполняет все проверки.
+func check(ctx context.Context, checkers map[string]Checker) response {
+	resp := response{
+		Status: statusOK,
+		Checks: make(map[string]checkResult, len(checkers)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := checkResult{Status: statusOK}
+			if err := checker.Check(ctx); err != nil {
+				result = checkResult{Status: statusUnavailable, Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			resp.Checks[name] = result
+			if result.Status != statusOK {
+				resp.Status = statusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	return resp
+}
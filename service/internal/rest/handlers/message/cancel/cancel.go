@@ -0,0 +1,60 @@
+package cancel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// MessageCanceller описывает поведение объекта, который отменяет обработку сообщений.
+type MessageCanceller interface {
+	// CancelMessage отменяет обработку сообщения.
+	CancelMessage(ctx context.Context, id uint64) error
+}
+
+type request struct {
+	ID uint64 `uri:"id" binding:"required,gte=1"`
+}
+
+// New возвращает новый хендлер, который отменяет обработку сообщения.
+//
+//	@Summary		Отменить сообщение
+//	@Description	Отмена обработки сообщения. Обработку можно отменить, пока обработчик не подтвердил получение сообщения.
+//	@Tags			messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	uint	true	"ID сообщения"
+//	@Success		204
+//	@Failure		400	{object}	mwerror.ErrorResponse
+//	@Failure		404	{object}	mwerror.ErrorResponse
+//	@Failure		409	{object}	mwerror.ErrorResponse
+//	@Failure		500	{object}	mwerror.ErrorResponse
+//	@Router			/messages/{id}/cancel [post]
+func New(m MessageCanceller) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBindUri(&req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if err := m.CancelMessage(c, req.ID); err != nil {
+			switch {
+			case errors.Is(err, models.ErrMessageNotFound):
+				c.AbortWithError(http.StatusNotFound, err)
+			case errors.Is(err, models.ErrInvalidStatusTransition), errors.Is(err, models.ErrMessageStatusConflict):
+				c.AbortWithError(http.StatusConflict, err)
+			default:
+				c.AbortWithError(http.StatusInternalServerError, err)
+			}
+
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -2,20 +2,30 @@ package create
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/sedonn/message-service/internal/domain/models"
 )
 
+// headerIdempotencyReplayed это заголовок ответа на повторный запрос с тем же ключом идемпотентности.
+const headerIdempotencyReplayed = "Idempotency-Replayed"
+
 // MessageCreator описывает поведение объекта, который создает новые сообщения.
 type MessageCreator interface {
-	CreateMessage(ctx context.Context, content string) (uint64, error)
+	CreateMessage(ctx context.Context, content, idempotencyKey string) (uint64, bool, error)
 }
 
 type request struct {
 	Content string `json:"content" binding:"required,lte=256"`
 }
 
+type header struct {
+	IdempotencyKey string `header:"Idempotency-Key" binding:"omitempty,max=255"`
+}
+
 type response struct {
 	ID uint64 `json:"id"`
 }
@@ -27,26 +37,44 @@ type response struct {
 //	@Tags			messages
 //	@Accept			json
 //	@Produce		json
-//	@Param			message	body		request	true	"Содержимое сообщения"
-//	@Success		200		{object}	response
-//	@Failure		400		{object}	mwerror.ErrorResponse
-//	@Failure		404		{object}	mwerror.ErrorResponse
-//	@Failure		500		{object}	mwerror.ErrorResponse
+//	@Param			message			body		request	true	"Содержимое сообщения"
+//	@Param			Idempotency-Key	header		string	false	"Ключ идемпотентности. Повторный запрос с тем же ключом возвращает ранее созданное сообщение"
+//	@Success		200				{object}	response
+//	@Header			200				{string}	Idempotency-Replayed	"true, если сообщение создано ранее по тому же ключу идемпотентности"
+//	@Failure		400				{object}	mwerror.ErrorResponse
+//	@Failure		404				{object}	mwerror.ErrorResponse
+//	@Failure		422				{object}	mwerror.ErrorResponse
+//	@Failure		500				{object}	mwerror.ErrorResponse
 //	@Router			/messages [post]
 func New(m MessageCreator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var h header
+		if err := c.ShouldBindHeader(&h); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
 		var req request
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.AbortWithError(http.StatusBadRequest, err)
 			return
 		}
 
-		id, err := m.CreateMessage(c, req.Content)
+		id, replayed, err := m.CreateMessage(c, req.Content, h.IdempotencyKey)
 		if err != nil {
+			if errors.Is(err, models.ErrIdempotencyKeyConflict) {
+				c.AbortWithError(http.StatusUnprocessableEntity, err)
+				return
+			}
+
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
+		if replayed {
+			c.Header(headerIdempotencyReplayed, "true")
+		}
+
 		c.JSON(http.StatusOK, response{ID: id})
 	}
 }
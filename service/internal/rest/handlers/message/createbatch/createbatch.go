@@ -0,0 +1,100 @@
+package createbatch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// MessageBatchCreator описывает поведение объекта, который создает новые сообщения пакетом.
+type MessageBatchCreator interface {
+	// CheckBatchSize проверяет, что размер пакета сообщений не превышает допустимый.
+	CheckBatchSize(size int) error
+	// CreateMessages создает новые сообщения и возвращает их ID в порядке передачи содержимого.
+	CreateMessages(ctx context.Context, contents []string) ([]uint64, error)
+}
+
+type request struct {
+	Messages []item `json:"messages" binding:"required,min=1"`
+}
+
+type item struct {
+	Content string `json:"content" binding:"required,lte=256"`
+}
+
+type response struct {
+	Items []itemResult `json:"items"`
+}
+
+// itemResult это результат создания одного сообщения пакета. Заполнено либо ID, либо Error.
+type itemResult struct {
+	ID    uint64 `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// New возвращает новый хендлер, который сохраняет данные сообщений пакетом.
+//
+//	@Summary		Создать сообщения пакетом
+//	@Description	Создание новых сообщений пакетом. Каждое сообщение проверяется отдельно, сообщения
+//	@Description	с ошибками не создаются. Результаты возвращаются в порядке сообщений запроса.
+//	@Description	Если пакет больше допустимого размера, запрос отклоняется целиком до проверки сообщений.
+//	@Tags			messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			messages	body		request	true	"Сообщения. Размер пакета ограничен значением из конфигурации"
+//	@Success		200			{object}	response
+//	@Failure		400			{object}	mwerror.ErrorResponse
+//	@Failure		500			{object}	mwerror.ErrorResponse
+//	@Router			/messages/batch [post]
+func New(m MessageBatchCreator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if err := m.CheckBatchSize(len(req.Messages)); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		resp := response{Items: make([]itemResult, len(req.Messages))}
+
+		valid := make([]int, 0, len(req.Messages))
+		contents := make([]string, 0, len(req.Messages))
+		for i, it := range req.Messages {
+			if err := binding.Validator.ValidateStruct(it); err != nil {
+				resp.Items[i].Error = err.Error()
+				continue
+			}
+
+			valid = append(valid, i)
+			contents = append(contents, it.Content)
+		}
+
+		if len(contents) > 0 {
+			ids, err := m.CreateMessages(c, contents)
+			if err != nil {
+				if errors.Is(err, models.ErrBatchTooLarge) {
+					c.AbortWithError(http.StatusBadRequest, err)
+					return
+				}
+
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+
+			for j, i := range valid {
+				resp.Items[i].ID = ids[j]
+			}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
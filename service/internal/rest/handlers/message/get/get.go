@@ -2,7 +2,9 @@ package get
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sedonn/message-service/internal/domain/models"
@@ -10,36 +12,52 @@ import (
 
 // MessageCreator описывает поведение объекта, который извлекает и фильтрует данные сообщений.
 type MessageGetter interface {
-	// GetMessages получает все сообщения.
-	GetMessages(ctx context.Context, pageID uint) ([]models.Message, error)
-
-	// GetProcessedMessages получает только обработанные сообщения.
-	GetProcessedMessages(ctx context.Context, pageID uint) ([]models.Message, error)
-
-	// GetUnprocessedMessages получает только необработанные сообщения.
-	GetUnprocessedMessages(ctx context.Context, pageID uint) ([]models.Message, error)
+	// GetMessages получает страницу сообщений, удовлетворяющих запросу.
+	GetMessages(ctx context.Context, q models.MessageQuery) (models.MessagePage, error)
 }
 
 type request struct {
-	PageID    *uint `form:"page,default=0" binding:"numeric,gte=0"`
-	Processed *bool `form:"processed" binding:"omitempty,boolean"`
+	Cursor        string     `form:"cursor"`
+	Limit         int        `form:"limit" binding:"omitempty,gte=1"`
+	Statuses      []string   `form:"status" binding:"omitempty,max=6,dive,oneof=created queued processing processed failed cancelled"`
+	IDs           []uint64   `form:"id" binding:"omitempty,max=100,dive,gte=1"`
+	Content       string     `form:"content" binding:"omitempty,lte=256"`
+	CreatedFrom   *time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo     *time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	ProcessedFrom *time.Time `form:"processed_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	ProcessedTo   *time.Time `form:"processed_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	SortBy        string     `form:"sort_by,default=created_at" binding:"oneof=created_at processed_at id"`
+	Order         string     `form:"order,default=asc" binding:"oneof=asc desc"`
 }
 
-type response []models.Message
+type response struct {
+	Items      []models.Message `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
 
 // New возвращает новый хендлер, который извлекает данные сообщений.
 //
 //	@Summary		Получить сообщения
-//	@Description	Получение сообщений.
+//	@Description	Получение сообщений с фильтрацией и сортировкой.
 //	@Tags			messages
 //	@Accept			json
 //	@Produce		json
-//	@Param			page		query		uint	false	"Номер страницы. Если пуст - 0"
-//	@Param			processed	query		bool	false	"Статус - обработано. Если пусто - выводит все сообщения"
-//	@Success		200			{array}		models.Message
-//	@Failure		400			{object}	mwerror.ErrorResponse
-//	@Failure		404			{object}	mwerror.ErrorResponse
-//	@Failure		500			{object}	mwerror.ErrorResponse
+//	@Param			cursor			query		string		false	"Курсор следующей страницы из предыдущего ответа. Если пуст - первая страница"
+//	@Param			limit			query		int			false	"Размер страницы. Ограничен максимальным значением из конфигурации"
+//	@Param			status			query		[]string	false	"Статусы сообщений. Если пусто - выводит все сообщения"	collectionFormat(multi)	Enums(created, queued, processing, processed, failed, cancelled)
+//	@Param			id				query		[]uint		false	"ID сообщений"											collectionFormat(multi)
+//	@Param			content			query		string		false	"Подстрока содержимого сообщения без учета регистра"
+//	@Param			created_from	query		string		false	"Начало периода создания включительно, RFC 3339"
+//	@Param			created_to		query		string		false	"Конец периода создания не включительно, RFC 3339"
+//	@Param			processed_from	query		string		false	"Начало периода обработки включительно, RFC 3339"
+//	@Param			processed_to	query		string		false	"Конец периода обработки не включительно, RFC 3339"
+//	@Param			sort_by			query		string		false	"Поле сортировки. Если пусто - created_at"	Enums(created_at, processed_at, id)
+//	@Param			order			query		string		false	"Направление сортировки. Если пусто - asc"	Enums(asc, desc)
+//	@Param			X-Read-Primary	header		bool		false	"Читать с основной базы данных, а не с реплики. Позволяет сразу прочитать только что созданное сообщение"
+//	@Success		200				{object}	response
+//	@Failure		400				{object}	mwerror.ErrorResponse
+//	@Failure		404				{object}	mwerror.ErrorResponse
+//	@Failure		500				{object}	mwerror.ErrorResponse
 //	@Router			/messages [get]
 func New(m MessageGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -49,24 +67,58 @@ func New(m MessageGetter) gin.HandlerFunc {
 			return
 		}
 
-		var (
-			messages []models.Message
-			err      error
-		)
-		switch {
-		case req.Processed == nil:
-			messages, err = m.GetMessages(c, *req.PageID)
-		case *req.Processed:
-			messages, err = m.GetProcessedMessages(c, *req.PageID)
-		case !*req.Processed:
-			messages, err = m.GetUnprocessedMessages(c, *req.PageID)
+		q := models.MessageQuery{
+			Filter: models.MessageFilter{
+				IDs:           req.IDs,
+				Statuses:      req.Statuses,
+				Content:       req.Content,
+				CreatedFrom:   req.CreatedFrom,
+				CreatedTo:     req.CreatedTo,
+				ProcessedFrom: req.ProcessedFrom,
+				ProcessedTo:   req.ProcessedTo,
+			},
+			Sort: models.MessageSort{
+				Field: req.SortBy,
+				Order: req.Order,
+			},
+			Page: models.PageRequest{Limit: req.Limit},
 		}
 
+		if req.Cursor != "" {
+			cursor, err := models.DecodeCursor(req.Cursor)
+			if err != nil {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+
+			q.Page.After = &cursor
+		}
+
+		page, err := m.GetMessages(c, q)
 		if err != nil {
+			if errors.Is(err, models.ErrInvalidCursor) {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, response(messages))
+		c.JSON(http.StatusOK, newResponse(page))
 	}
 }
+
+// newResponse создает ответ со страницей сообщений.
+func newResponse(page models.MessagePage) response {
+	resp := response{Items: page.Items}
+	if resp.Items == nil {
+		resp.Items = []models.Message{}
+	}
+
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+
+	return resp
+}
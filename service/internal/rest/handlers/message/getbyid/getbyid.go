@@ -0,0 +1,60 @@
+package getbyid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// MessageByIDGetter описывает поведение объекта, который извлекает данные одного сообщения.
+type MessageByIDGetter interface {
+	// GetMessage получает сообщение по его ID.
+	GetMessage(ctx context.Context, id uint64) (models.Message, error)
+}
+
+type request struct {
+	ID uint64 `uri:"id" binding:"required,gte=1"`
+}
+
+type response models.Message
+
+// New возвращает новый хендлер, который извлекает данные сообщения по его ID.
+//
+//	@Summary		Получить сообщение
+//	@Description	Получение сообщения по ID.
+//	@Tags			messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		uint	true	"ID сообщения"
+//	@Param			X-Read-Primary	header		bool	false	"Читать с основной базы данных, а не с реплики. Позволяет сразу прочитать только что созданное сообщение"
+//	@Success		200				{object}	models.Message
+//	@Failure		400				{object}	mwerror.ErrorResponse
+//	@Failure		404				{object}	mwerror.ErrorResponse
+//	@Failure		500				{object}	mwerror.ErrorResponse
+//	@Router			/messages/{id} [get]
+func New(m MessageByIDGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBindUri(&req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		message, err := m.GetMessage(c, req.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrMessageNotFound) {
+				c.AbortWithError(http.StatusNotFound, err)
+				return
+			}
+
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, response(message))
+	}
+}
@@ -3,14 +3,22 @@ package messagerest
 import (
 	"github.com/gin-gonic/gin"
 
+	"github.com/sedonn/message-service/internal/rest/handlers/message/cancel"
 	"github.com/sedonn/message-service/internal/rest/handlers/message/create"
+	"github.com/sedonn/message-service/internal/rest/handlers/message/createbatch"
 	"github.com/sedonn/message-service/internal/rest/handlers/message/get"
+	"github.com/sedonn/message-service/internal/rest/handlers/message/getbyid"
+	"github.com/sedonn/message-service/internal/rest/handlers/message/stats"
 )
 
 // Messenger описывает поведение объекта, который обеспечивает бизнес-логику работы с сообщениями.
 type Messenger interface {
 	get.MessageGetter
+	getbyid.MessageByIDGetter
+	stats.MessageStatsGetter
 	create.MessageCreator
+	createbatch.MessageBatchCreator
+	cancel.MessageCanceller
 }
 
 // Handler это корневой хендлер сообщений.
@@ -30,6 +38,10 @@ func (h *Handler) BindTo(router *gin.RouterGroup) {
 	message := router.Group("/messages")
 	{
 		message.GET("/", get.New(h.messenger))
+		message.GET("/stats", stats.New(h.messenger))
+		message.GET("/:id", getbyid.New(h.messenger))
 		message.POST("/", create.New(h.messenger))
+		message.POST("/batch", createbatch.New(h.messenger))
+		message.POST("/:id/cancel", cancel.New(h.messenger))
 	}
 }
@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// MessageStatsGetter описывает поведение объекта, который извлекает статистику обработки сообщений.
+type MessageStatsGetter interface {
+	// GetMessageStats получает статистику обработки сообщений.
+	GetMessageStats(ctx context.Context, window time.Duration) (models.MessageStats, error)
+}
+
+type request struct {
+	Window time.Duration `form:"window" binding:"omitempty,gt=0"`
+}
+
+type response struct {
+	Total       uint64  `json:"total"`
+	Processed   uint64  `json:"processed"`
+	Failed      uint64  `json:"failed"`
+	Cancelled   uint64  `json:"cancelled"`
+	Unprocessed uint64  `json:"unprocessed"`
+	Latency     latency `json:"latency"`
+}
+
+type latency struct {
+	Window     string   `json:"window"`
+	Count      uint64   `json:"count"`
+	P50Seconds *float64 `json:"p50_seconds"`
+	P90Seconds *float64 `json:"p90_seconds"`
+	P95Seconds *float64 `json:"p95_seconds"`
+	P99Seconds *float64 `json:"p99_seconds"`
+}
+
+// New возвращает новый хендлер, который извлекает статистику обработки сообщений.
+//
+//	@Summary		Получить статистику
+//	@Description	Получение количества сообщений и перцентилей задержки их обработки.
+//	@Tags			messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			window			query		string	false	"Период расчета задержки обработки, например 1h. Если пуст - значение из конфигурации"
+//	@Param			X-Read-Primary	header		bool	false	"Читать с основной базы данных, а не с реплики. Позволяет сразу прочитать только что созданное сообщение"
+//	@Success		200				{object}	response
+//	@Failure		400				{object}	mwerror.ErrorResponse
+//	@Failure		500				{object}	mwerror.ErrorResponse
+//	@Router			/messages/stats [get]
+func New(m MessageStatsGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		s, err := m.GetMessageStats(c, req.Window)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, newResponse(s))
+	}
+}
+
+// newResponse создает ответ со статистикой обработки сообщений.
+func newResponse(s models.MessageStats) response {
+	return response{
+		Total:       s.Total,
+		Processed:   s.Processed,
+		Failed:      s.Failed,
+		Cancelled:   s.Cancelled,
+		Unprocessed: s.Unprocessed,
+		Latency: latency{
+			Window:     s.Window.String(),
+			Count:      s.Latency.Count,
+			P50Seconds: seconds(s.Latency.P50),
+			P90Seconds: seconds(s.Latency.P90),
+			P95Seconds: seconds(s.Latency.P95),
+			P99Seconds: seconds(s.Latency.P99),
+		},
+	}
+}
+
+// seconds возвращает длительность в секундах.
+func seconds(d *time.Duration) *float64 {
+	if d == nil {
+		return nil
+	}
+
+	s := d.Seconds()
+
+	return &s
+}
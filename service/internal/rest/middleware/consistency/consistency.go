@@ -0,0 +1,25 @@
+package mwconsistency
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sedonn/message-service/internal/pkg/consistency"
+)
+
+// HeaderReadPrimary это заголовок запроса, который требует читать данные с основной базы данных.
+// Позволяет клиенту сразу прочитать только что созданное сообщение, которое еще не попало на реплики.
+const HeaderReadPrimary = "X-Read-Primary"
+
+// New создает middleware, которое направляет чтение запроса на основную базу данных,
+// если в запросе установлен заголовок HeaderReadPrimary.
+func New() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if primary, _ := strconv.ParseBool(c.GetHeader(HeaderReadPrimary)); primary {
+			c.Request = c.Request.WithContext(consistency.WithPrimary(c.Request.Context()))
+		}
+
+		c.Next()
+	}
+}
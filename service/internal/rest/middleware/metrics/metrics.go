@@ -0,0 +1,28 @@
+package mwmetrics
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+)
+
+// unmatchedRoute это метка запросов, для которых не найден маршрут.
+const unmatchedRoute = "unmatched"
+
+// New создает middleware для учета метрик HTTP-запросов.
+func New(m *metrics.HTTP) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		m.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -1,183 +1,393 @@
 package message
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"log/slog"
+	"time"
 
 	"context"
 
+	"github.com/sedonn/message-service/internal/config"
 	"github.com/sedonn/message-service/internal/domain/events"
 	"github.com/sedonn/message-service/internal/domain/models"
 	"github.com/sedonn/message-service/internal/event/kafka/consumer"
+	"github.com/sedonn/message-service/internal/event/kafka/producer"
+	"github.com/sedonn/message-service/internal/pkg/consistency"
 	"github.com/sedonn/message-service/internal/pkg/logger"
 	messagerest "github.com/sedonn/message-service/internal/rest/handlers/message"
 )
 
 // MessageProvider описывает поведение объекта, который обеспечивает получение данных сообщений.
 type MessageProvider interface {
-	// Messages возвращает данные о всех сообщениях.
-	Messages(ctx context.Context, pageID, pageSize uint) ([]models.Message, error)
+	// Message возвращает данные сообщения по его ID.
+	// Чтение выполняется на основной базе данных, если этого требует контекст, см. consistency.WithPrimary.
+	Message(ctx context.Context, id uint64) (models.Message, error)
 
-	// ProcessedMessages возвращает данные только обработанных сообщений.
-	ProcessedMessages(ctx context.Context, pageID, pageSize uint) ([]models.Message, error)
+	// Messages возвращает страницу сообщений, удовлетворяющих запросу.
+	Messages(ctx context.Context, q models.MessageQuery) (models.MessagePage, error)
 
-	// UnprocessedMessages возвращает данные только необработанных сообщений.
-	UnprocessedMessages(ctx context.Context, pageID, pageSize uint) ([]models.Message, error)
+	// MessageStats возвращает статистику обработки сообщений.
+	// Перцентили задержки считаются по сообщениям, обработанным после since.
+	MessageStats(ctx context.Context, since time.Time) (models.MessageStats, error)
 }
 
 // MessageSaver описывает поведение объекта, который обеспечивает сохранение данных сообщений.
 type MessageSaver interface {
-	// SaveMessage сохраняет данные нового сообщения.
-	SaveMessage(ctx context.Context, m models.Message) (uint64, error)
+	// SaveMessage сохраняет данные нового сообщения и событие старта его обработки в одной транзакции.
+	// Если передан ключ идемпотентности, он сохраняется в той же транзакции. Если действующий ключ
+	// уже существует, возвращает ID ранее созданного по нему сообщения и признак повтора.
+	SaveMessage(ctx context.Context, m models.Message, key *models.IdempotencyKey) (uint64, bool, error)
+
+	// SaveMessages сохраняет данные новых сообщений и события старта их обработки в одной транзакции.
+	// Возвращает ID сообщений в порядке их передачи.
+	SaveMessages(ctx context.Context, messages []models.Message) ([]uint64, error)
 }
 
 // MessageUpdater описывает поведение объекта, который обеспечивает обновление данных сообщений.
 type MessageUpdater interface {
-	// UpdateMessage сохраняет данные существующего сообщения.
-	UpdateMessage(ctx context.Context, m models.Message) (models.Message, error)
-}
-
-// MessageEventProducer описывает поведение объекта, который обеспечивает отправку событий связанных с данными сообщений.
-type MessageEventProducer interface {
-	// NotifyStartProcessingMessage создает событие старта обработки сообщения.
-	NotifyStartProcessingMessage(e events.StartProcessingMessage) error
+	// UpdateMessageStatus изменяет статус сообщения, если его текущий статус равен from.
+	// Возвращает models.ErrMessageStatusConflict, если статус сообщения уже изменен,
+	// и models.ErrMessageNotFound, если сообщения не существует.
+	UpdateMessageStatus(ctx context.Context, id uint64, from string, u models.MessageStatusUpdate) error
 }
 
 // Message предоставляет бизнес-логику работы с сообщениями.
 type Message struct {
-	log                  *slog.Logger
-	messageProvider      MessageProvider
-	messageSaver         MessageSaver
-	messageUpdater       MessageUpdater
-	messageEventProducer MessageEventProducer
+	log             *slog.Logger
+	cfg             *config.MessagesConfig
+	messageProvider MessageProvider
+	messageSaver    MessageSaver
+	messageUpdater  MessageUpdater
 }
 
 var _ messagerest.Messenger = (*Message)(nil)
 var _ consumer.MessageEventSubscriber = (*Message)(nil)
+var _ producer.MessageEventSubscriber = (*Message)(nil)
 
 // New создает новый сервис для работы с сообщениями.
-func New(log *slog.Logger, mp MessageProvider, ms MessageSaver, mu MessageUpdater, mep MessageEventProducer) *Message {
+func New(log *slog.Logger, cfg *config.MessagesConfig, mp MessageProvider, ms MessageSaver, mu MessageUpdater) *Message {
 	return &Message{
-		log:                  log,
-		messageProvider:      mp,
-		messageSaver:         ms,
-		messageUpdater:       mu,
-		messageEventProducer: mep,
+		log:             log,
+		cfg:             cfg,
+		messageProvider: mp,
+		messageSaver:    ms,
+		messageUpdater:  mu,
 	}
 }
 
-// GetMessages получает все сообщения.
-func (m *Message) GetMessages(ctx context.Context, pageID uint) ([]models.Message, error) {
-	const (
-		op       = "message.GetMessages"
-		pageSize = 10
-	)
-	log := m.log.With(slog.String("op", op))
+// GetMessage получает сообщение по его ID.
+func (m *Message) GetMessage(ctx context.Context, id uint64) (models.Message, error) {
+	const op = "message.GetMessage"
+	log := m.log.With(slog.String("op", op), slog.Uint64("message_id", id))
 
-	log.Info("attempt to get messages", slog.Int("page_size", pageSize))
+	log.Info("attempt to get message")
 
-	messages, err := m.messageProvider.Messages(ctx, pageID, pageSize)
+	message, err := m.messageProvider.Message(ctx, id)
 	if err != nil {
-		log.Error("failed to get messages", logger.StringError(err))
+		if errors.Is(err, models.ErrMessageNotFound) {
+			log.Warn("message not found")
 
-		return nil, err
+			return models.Message{}, err
+		}
+
+		log.Error("failed to get message", logger.StringError(err))
+
+		return models.Message{}, err
 	}
 
-	log.Info("success to get messages", slog.Int("messages_count", len(messages)))
+	log.Info("success to get message")
 
-	return messages, nil
+	return message, nil
 }
 
-// GetProcessedMessages получает только обработанные сообщения.
-func (m *Message) GetProcessedMessages(ctx context.Context, pageID uint) ([]models.Message, error) {
-	const (
-		op       = "message.GetProcessedMessages"
-		pageSize = 10
-	)
+// GetMessages получает страницу сообщений, удовлетворяющих запросу.
+func (m *Message) GetMessages(ctx context.Context, q models.MessageQuery) (models.MessagePage, error) {
+	const op = "message.GetMessages"
 	log := m.log.With(slog.String("op", op))
 
-	log.Info("attempt to get processed messages", slog.Int("page_size", pageSize))
+	if q.Page.After != nil && !q.Page.After.Matches(q.Sort) {
+		log.Warn("cursor does not match sort order")
 
-	messages, err := m.messageProvider.ProcessedMessages(ctx, pageID, pageSize)
+		return models.MessagePage{}, models.ErrInvalidCursor
+	}
+
+	q.Page.Limit = m.pageLimit(q.Page.Limit)
+	log.Info("attempt to get messages",
+		slog.Int("page_size", q.Page.Limit),
+		slog.String("sort_by", q.Sort.Field),
+		slog.String("order", q.Sort.Order),
+	)
+
+	page, err := m.messageProvider.Messages(ctx, q)
 	if err != nil {
-		log.Error("failed to get processed messages", logger.StringError(err))
+		log.Error("failed to get messages", logger.StringError(err))
 
-		return nil, err
+		return models.MessagePage{}, err
 	}
 
-	log.Info("success to get processed messages", slog.Int("messages_count", len(messages)))
+	log.Info("success to get messages", slog.Int("messages_count", len(page.Items)))
 
-	return messages, nil
+	return page, nil
 }
 
-// GetUnprocessedMessages получает только необработанные сообщения.
-func (m *Message) GetUnprocessedMessages(ctx context.Context, pageID uint) ([]models.Message, error) {
-	const (
-		op       = "message.GetUnprocessedMessages"
-		pageSize = 10
-	)
+// GetMessageStats получает статистику обработки сообщений.
+// Задержка обработки считается по сообщениям, обработанным за последний период window.
+func (m *Message) GetMessageStats(ctx context.Context, window time.Duration) (models.MessageStats, error) {
+	const op = "message.GetMessageStats"
 	log := m.log.With(slog.String("op", op))
 
-	log.Info("attempt to get unprocessed messages", slog.Int("page_size", pageSize))
+	window = m.statsWindow(window)
+	log.Info("attempt to get message stats", slog.Duration("window", window))
 
-	messages, err := m.messageProvider.UnprocessedMessages(ctx, pageID, pageSize)
+	s, err := m.messageProvider.MessageStats(ctx, time.Now().Add(-window))
 	if err != nil {
-		log.Error("failed to get unprocessed messages", logger.StringError(err))
+		log.Error("failed to get message stats", logger.StringError(err))
 
-		return nil, err
+		return models.MessageStats{}, err
 	}
 
-	log.Info("success to get unprocessed messages", slog.Int("messages_count", len(messages)))
+	s.Window = window
+	log.Info("success to get message stats")
 
-	return messages, nil
+	return s, nil
 }
 
-// CreateMessage создает новое сообщение.
-func (m *Message) CreateMessage(ctx context.Context, content string) (uint64, error) {
-	const op = "message.GetMessages"
+// CreateMessage создает новое сообщение. Событие старта обработки сообщения
+// сохраняется вместе с ним и отправляется в Kafka асинхронно.
+//
+// Если передан ключ идемпотентности, повторный запрос с тем же ключом и содержимым в течение TTL ключа
+// возвращает ID ранее созданного сообщения и признак повтора. Повторный запрос с тем же ключом
+// и другим содержимым возвращает models.ErrIdempotencyKeyConflict.
+func (m *Message) CreateMessage(ctx context.Context, content, idempotencyKey string) (uint64, bool, error) {
+	const op = "message.CreateMessage"
 	log := m.log.With(slog.String("op", op))
 
-	log.Info("attempt to create message", slog.Int("message_size", len(content)))
+	log.Info("attempt to create message",
+		slog.Int("message_size", len(content)),
+		slog.Bool("idempotent", idempotencyKey != ""),
+	)
 
-	id, err := m.messageSaver.SaveMessage(ctx, models.Message{Content: content})
+	var key *models.IdempotencyKey
+	if idempotencyKey != "" {
+		hash := sha256.Sum256([]byte(content))
+		key = &models.IdempotencyKey{
+			Key:         idempotencyKey,
+			RequestHash: hex.EncodeToString(hash[:]),
+			ExpiresAt:   time.Now().Add(m.cfg.Idempotency.TTL),
+		}
+	}
+
+	id, replayed, err := m.messageSaver.SaveMessage(ctx, models.Message{Content: content, Status: models.MessageStatusCreated}, key)
 	if err != nil {
+		if errors.Is(err, models.ErrIdempotencyKeyConflict) {
+			log.Warn("idempotency key is already used for another request")
+
+			return 0, false, err
+		}
+
 		log.Error("failed to create message", logger.StringError(err))
 
-		return 0, err
+		return 0, false, err
 	}
 
 	log = log.With(slog.Uint64("message_id", id))
+	if replayed {
+		log.Info("message is already created by idempotency key")
+
+		return id, true, nil
+	}
+
 	log.Info("success to create message")
 
-	e := events.StartProcessingMessage{
-		ID:      id,
-		Content: content,
+	return id, false, nil
+}
+
+// CreateMessages создает новые сообщения одним пакетом. События старта обработки сообщений
+// сохраняются вместе с ними и отправляются в Kafka асинхронно.
+//
+// Возвращает ID сообщений в порядке передачи содержимого. Если размер пакета превышает
+// ограничение конфигурации, возвращает models.ErrBatchTooLarge.
+func (m *Message) CreateMessages(ctx context.Context, contents []string) ([]uint64, error) {
+	const op = "message.CreateMessages"
+	log := m.log.With(slog.String("op", op), slog.Int("batch_size", len(contents)))
+
+	if err := m.CheckBatchSize(len(contents)); err != nil {
+		log.Warn("batch is too large", slog.Int("max_batch_size", m.cfg.Batch.MaxSize))
+
+		return nil, err
 	}
 
-	if err := m.messageEventProducer.NotifyStartProcessingMessage(e); err != nil {
-		log.Error("failed to send message for processing", logger.StringError(err))
+	log.Info("attempt to create messages")
 
-		return 0, err
+	messages := make([]models.Message, len(contents))
+	for i, content := range contents {
+		messages[i] = models.Message{Content: content, Status: models.MessageStatusCreated}
 	}
 
-	log.Info("success to send message for processing")
+	ids, err := m.messageSaver.SaveMessages(ctx, messages)
+	if err != nil {
+		log.Error("failed to create messages", logger.StringError(err))
 
-	return id, nil
+		return nil, err
+	}
+
+	log.Info("success to create messages")
+
+	return ids, nil
 }
 
-// OnMessageProcessed implements consumer.MessageEventConsumer.
-func (m *Message) OnMessageProcessed(ctx context.Context, e events.CompleteProcessingMessage) {
-	const op = "message.OnMessageProcessed"
+// CheckBatchSize проверяет, что размер пакета сообщений не превышает ограничение конфигурации.
+// Возвращает models.ErrBatchTooLarge, если пакет слишком большой.
+func (m *Message) CheckBatchSize(size int) error {
+	if size > m.cfg.Batch.MaxSize {
+		return models.ErrBatchTooLarge
+	}
+
+	return nil
+}
+
+// CancelMessage отменяет обработку сообщения.
+// Обработку можно отменить, пока обработчик не подтвердил получение сообщения.
+// Если событие старта обработки еще не отправлено, оно удаляется вместе с отменой.
+func (m *Message) CancelMessage(ctx context.Context, id uint64) error {
+	const op = "message.CancelMessage"
+	log := m.log.With(slog.String("op", op), slog.Uint64("message_id", id))
+
+	log.Info("attempt to cancel message")
+	if err := m.transition(ctx, id, models.MessageStatusUpdate{Status: models.MessageStatusCancelled}); err != nil {
+		switch {
+		case errors.Is(err, models.ErrMessageNotFound):
+			log.Warn("message not found")
+		case errors.Is(err, models.ErrInvalidStatusTransition):
+			log.Warn("message can not be cancelled", logger.StringError(err))
+		default:
+			log.Error("failed to cancel message", logger.StringError(err))
+		}
+
+		return err
+	}
+
+	log.Info("success to cancel message")
+
+	return nil
+}
+
+// OnMessageQueued implements producer.MessageEventSubscriber.
+//
+// Сообщение может быть отменено, пока его событие старта обработки отправляется в Kafka.
+// Такое сообщение остается отмененным, а событие об отправке пропускается без ошибки.
+func (m *Message) OnMessageQueued(ctx context.Context, id uint64) error {
+	const op = "message.OnMessageQueued"
+	log := m.log.With(slog.String("op", op), slog.Uint64("message_id", id))
+
+	log.Debug("attempt to mark message as queued")
+	now := time.Now()
+	err := m.transition(ctx, id, models.MessageStatusUpdate{Status: models.MessageStatusQueued, DispatchedAt: &now})
+	if errors.Is(err, models.ErrInvalidStatusTransition) || errors.Is(err, models.ErrMessageStatusConflict) {
+		message, mErr := m.messageProvider.Message(consistency.WithPrimary(ctx), id)
+		if mErr == nil && message.Status == models.MessageStatusCancelled {
+			log.Debug("message was cancelled while its event was being sent")
+
+			return nil
+		}
+	}
+
+	if err != nil {
+		log.Warn("failed to mark message as queued", logger.StringError(err))
+
+		return err
+	}
+
+	log.Debug("success to mark message as queued")
+
+	return nil
+}
+
+// OnMessageAcknowledged implements consumer.MessageEventSubscriber.
+func (m *Message) OnMessageAcknowledged(ctx context.Context, e events.AcknowledgeProcessingMessage) error {
+	const op = "message.OnMessageAcknowledged"
 	log := m.log.With(slog.String("op", op), slog.Uint64("message_id", e.ID))
 
-	log.Info("attempt to update processed message")
-	_, err := m.messageUpdater.UpdateMessage(ctx, models.Message{
-		ID:          e.ID,
+	log.Info("attempt to mark message as processing")
+	if err := m.transition(ctx, e.ID, models.MessageStatusUpdate{Status: models.MessageStatusProcessing}); err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidStatusTransition):
+			log.Warn("stale processing acknowledgement", logger.StringError(err))
+		case errors.Is(err, models.ErrMessageNotFound):
+			log.Error("processing acknowledgement for unknown message")
+		default:
+			log.Error("failed to mark message as processing", logger.StringError(err))
+		}
+
+		return err
+	}
+
+	log.Info("success to mark message as processing")
+
+	return nil
+}
+
+// OnMessageProcessed implements consumer.MessageEventSubscriber.
+//
+// Повторное событие о завершении обработки не изменяет сообщение и возвращает models.ErrMessageAlreadyProcessed.
+// Событие для несуществующего сообщения возвращает models.ErrMessageNotFound.
+func (m *Message) OnMessageProcessed(ctx context.Context, e events.CompleteProcessingMessage) error {
+	const op = "message.OnMessageProcessed"
+	log := m.log.With(slog.String("op", op), slog.Uint64("message_id", e.ID), slog.String("status", e.Status))
+
+	u := models.MessageStatusUpdate{
+		Status:      models.MessageStatusProcessed,
 		ProcessedAt: &e.ProcessedAt,
-	})
+		Result:      e.Result,
+		ErrorReason: e.Error,
+	}
+	if e.Status == events.ProcessingStatusFailed {
+		u.Status = models.MessageStatusFailed
+	}
 
-	if err != nil {
-		log.Error("failed to update processed message", logger.StringError(err))
+	log.Info("attempt to update processed message")
+	if err := m.transition(ctx, e.ID, u); err != nil {
+		switch {
+		case errors.Is(err, models.ErrMessageAlreadyProcessed):
+			log.Warn("duplicate processing completion, message is already processed")
+		case errors.Is(err, models.ErrInvalidStatusTransition):
+			log.Warn("stale processing completion", logger.StringError(err))
+		case errors.Is(err, models.ErrMessageNotFound):
+			log.Error("processing completion for unknown message")
+		default:
+			log.Error("failed to update processed message", logger.StringError(err))
+		}
+
+		return err
 	}
 
 	log.Info("success to update processed message")
+
+	return nil
+}
+
+// pageLimit возвращает размер страницы с учетом ограничений конфигурации.
+func (m *Message) pageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return m.cfg.Pagination.DefaultLimit
+	case limit > m.cfg.Pagination.MaxLimit:
+		return m.cfg.Pagination.MaxLimit
+	}
+
+	return limit
+}
+
+// statsWindow возвращает период расчета статистики с учетом ограничений конфигурации.
+func (m *Message) statsWindow(window time.Duration) time.Duration {
+	switch {
+	case window <= 0:
+		return m.cfg.Stats.DefaultWindow
+	case window > m.cfg.Stats.MaxWindow:
+		return m.cfg.Stats.MaxWindow
+	}
+
+	return window
 }
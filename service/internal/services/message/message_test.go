@@ -0,0 +1,92 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// fakeRepository это MessageProvider и MessageUpdater, который хранит сообщения в памяти.
+type fakeRepository struct {
+	MessageProvider
+	messages map[uint64]models.Message
+}
+
+func (r *fakeRepository) Message(_ context.Context, id uint64) (models.Message, error) {
+	m, ok := r.messages[id]
+	if !ok {
+		return models.Message{}, models.ErrMessageNotFound
+	}
+
+	return m, nil
+}
+
+func (r *fakeRepository) UpdateMessageStatus(_ context.Context, id uint64, from string, u models.MessageStatusUpdate) error {
+	m, ok := r.messages[id]
+	if !ok {
+		return models.ErrMessageNotFound
+	}
+
+	if m.Status != from {
+		return models.ErrMessageStatusConflict
+	}
+
+	m.Status = u.Status
+	if u.ProcessedAt != nil {
+		m.ProcessedAt = u.ProcessedAt
+		m.Result = u.Result
+		m.ErrorReason = u.ErrorReason
+	}
+	if u.DispatchedAt != nil {
+		m.DispatchedAt = u.DispatchedAt
+	}
+
+	r.messages[id] = m
+
+	return nil
+}
+
+func newTestMessage(r *fakeRepository) *Message {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), &config.MessagesConfig{}, r, nil, r)
+}
+
+func TestOnMessageQueued(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "created", status: models.MessageStatusCreated, wantStatus: models.MessageStatusQueued},
+		{name: "cancelled while event is claimed", status: models.MessageStatusCancelled, wantStatus: models.MessageStatusCancelled},
+		{
+			name:       "processed before event is marked as sent",
+			status:     models.MessageStatusProcessed,
+			wantErr:    models.ErrInvalidStatusTransition,
+			wantStatus: models.MessageStatusProcessed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRepository{messages: map[uint64]models.Message{1: {ID: 1, Status: tt.status}}}
+
+			if err := newTestMessage(r).OnMessageQueued(context.Background(), 1); !errors.Is(err, tt.wantErr) {
+				t.Errorf("OnMessageQueued() error = %v, want %v", err, tt.wantErr)
+			}
+
+			m := r.messages[1]
+			if m.Status != tt.wantStatus {
+				t.Errorf("message status = %s, want %s", m.Status, tt.wantStatus)
+			}
+			if (m.DispatchedAt != nil) != (tt.wantStatus == models.MessageStatusQueued) {
+				t.Errorf("dispatched at = %v, want set only for queued message", m.DispatchedAt)
+			}
+		})
+	}
+}
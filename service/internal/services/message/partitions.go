@@ -0,0 +1,112 @@
+package message
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/pkg/logger"
+)
+
+// MessagePartitionManager описывает поведение объекта, который обеспечивает управление
+// месячными секциями таблицы сообщений.
+type MessagePartitionManager interface {
+	// CreateMessagePartitions создает секции с месяца from на count месяцев и возвращает имена созданных секций.
+	CreateMessagePartitions(ctx context.Context, from time.Time, count int) ([]string, error)
+
+	// DetachMessagePartitions отсоединяет секции, которые целиком предшествуют before, и возвращает имена
+	// отсоединенных секций и секций, пропущенных из-за необработанных сообщений.
+	// Если drop установлен, отсоединенные секции удаляются.
+	DetachMessagePartitions(ctx context.Context, before time.Time, drop bool) ([]string, []string, error)
+}
+
+// Partitions периодически заранее создает секции таблицы сообщений на ближайшие месяцы
+// и отсоединяет устаревшие секции.
+type Partitions struct {
+	log     *slog.Logger
+	cfg     *config.PartitionsConfig
+	manager MessagePartitionManager
+	wg      *sync.WaitGroup
+}
+
+// NewPartitions создает новый Partitions.
+func NewPartitions(log *slog.Logger, cfg *config.PartitionsConfig, mpm MessagePartitionManager) *Partitions {
+	return &Partitions{
+		log:     log,
+		cfg:     cfg,
+		manager: mpm,
+		wg:      &sync.WaitGroup{},
+	}
+}
+
+// Run запускает фоновое обслуживание секций. Первое обслуживание выполняется сразу при запуске,
+// чтобы секция текущего месяца существовала до записи сообщений. Обслуживание прекращается при отмене контекста.
+func (p *Partitions) Run(ctx context.Context) {
+	const op = "partitions.Run"
+	log := p.log.With(slog.String("op", op))
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.maintain(ctx)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Info("partitions maintenance start working")
+}
+
+// Stop ожидает завершения текущего обслуживания секций.
+func (p *Partitions) Stop() {
+	const op = "partitions.Stop"
+	log := p.log.With(slog.String("op", op))
+
+	log.Info("stopping partitions maintenance")
+	p.wg.Wait()
+	log.Info("partitions maintenance stopped")
+}
+
+// maintain создает секции текущего и следующих месяцев и отсоединяет секции старше максимального возраста.
+func (p *Partitions) maintain(ctx context.Context) {
+	const op = "partitions.maintain"
+	log := p.log.With(slog.String("op", op))
+
+	now := time.Now()
+
+	created, err := p.manager.CreateMessagePartitions(ctx, now, p.cfg.Premake+1)
+	if err != nil {
+		log.Error("failed to create partitions", logger.StringError(err))
+	} else if len(created) > 0 {
+		log.Info("success to create partitions", slog.Any("partitions", created))
+	}
+
+	if p.cfg.MaxAge <= 0 {
+		return
+	}
+
+	detached, skipped, err := p.manager.DetachMessagePartitions(ctx, now.Add(-p.cfg.MaxAge), p.cfg.Drop)
+	if err != nil {
+		log.Error("failed to detach partitions", logger.StringError(err))
+		return
+	}
+
+	if len(skipped) > 0 {
+		log.Warn("partitions with unprocessed messages are not detached", slog.Any("partitions", skipped))
+	}
+
+	if len(detached) > 0 {
+		log.Info("success to detach partitions", slog.Any("partitions", detached), slog.Bool("dropped", p.cfg.Drop))
+	}
+}
@@ -0,0 +1,164 @@
+package message
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/pkg/logger"
+	"github.com/sedonn/message-service/internal/pkg/metrics"
+)
+
+// MessagePurger описывает поведение объекта, который обеспечивает удаление устаревших данных.
+type MessagePurger interface {
+	// PurgeMessages удаляет не больше limit завершенных сообщений, созданных раньше before,
+	// и возвращает их количество. Если archive установлен, сообщения переносятся в архив.
+	PurgeMessages(ctx context.Context, before time.Time, limit int, archive bool) (int64, error)
+
+	// PurgeOutboxEvents удаляет не больше limit событий outbox, отправленных раньше before,
+	// и возвращает их количество.
+	PurgeOutboxEvents(ctx context.Context, before time.Time, limit int) (int64, error)
+
+	// PurgeIdempotencyKeys удаляет не больше limit истекших ключей идемпотентности и возвращает их количество.
+	PurgeIdempotencyKeys(ctx context.Context, limit int) (int64, error)
+}
+
+// Retention периодически удаляет завершенные сообщения и отправленные события outbox старше заданного возраста,
+// а также истекшие ключи идемпотентности.
+type Retention struct {
+	log     *slog.Logger
+	cfg     *config.RetentionConfig
+	purger  MessagePurger
+	metrics *metrics.Retention
+	wg      *sync.WaitGroup
+}
+
+// NewRetention создает новый Retention.
+func NewRetention(log *slog.Logger, cfg *config.RetentionConfig, mp MessagePurger, m *metrics.Retention) *Retention {
+	return &Retention{
+		log:     log,
+		cfg:     cfg,
+		purger:  mp,
+		metrics: m,
+		wg:      &sync.WaitGroup{},
+	}
+}
+
+// Run запускает фоновую очистку, если она включена. Очистка прекращается при отмене контекста.
+func (r *Retention) Run(ctx context.Context) {
+	const op = "retention.Run"
+	log := r.log.With(slog.String("op", op))
+
+	if !r.cfg.Enabled {
+		log.Info("retention is disabled")
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.purge(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Info(
+		"retention start working",
+		slog.Duration("max_age", r.cfg.MaxAge),
+		slog.Duration("outbox_max_age", r.cfg.OutboxMaxAge),
+		slog.Bool("archive", r.cfg.Archive),
+	)
+}
+
+// Stop ожидает завершения текущей очистки.
+func (r *Retention) Stop() {
+	const op = "retention.Stop"
+	log := r.log.With(slog.String("op", op))
+
+	log.Info("stopping retention")
+	r.wg.Wait()
+	log.Info("retention stopped")
+}
+
+// purge пакетами удаляет устаревшие сообщения, отправленные события outbox и истекшие ключи идемпотентности.
+func (r *Retention) purge(ctx context.Context) {
+	const op = "retention.purge"
+	log := r.log.With(slog.String("op", op))
+
+	action := "deleted"
+	if r.cfg.Archive {
+		action = "archived"
+	}
+
+	start := time.Now()
+	before := start.Add(-r.cfg.MaxAge)
+
+	messages, err := r.purgeBatches(ctx, func(ctx context.Context) (int64, error) {
+		return r.purger.PurgeMessages(ctx, before, r.cfg.BatchSize, r.cfg.Archive)
+	})
+	r.metrics.ObserveMessages(action, messages)
+	if err != nil {
+		r.metrics.ObserveRun(time.Since(start), err)
+		log.Error("failed to purge messages", slog.Int64("messages_count", messages), logger.StringError(err))
+		return
+	}
+
+	sentBefore := start.Add(-r.cfg.OutboxMaxAge)
+	outboxEvents, err := r.purgeBatches(ctx, func(ctx context.Context) (int64, error) {
+		return r.purger.PurgeOutboxEvents(ctx, sentBefore, r.cfg.BatchSize)
+	})
+	r.metrics.ObserveOutboxEvents(outboxEvents)
+	if err != nil {
+		r.metrics.ObserveRun(time.Since(start), err)
+		log.Error("failed to purge outbox events", slog.Int64("outbox_events_count", outboxEvents), logger.StringError(err))
+		return
+	}
+
+	keys, err := r.purgeBatches(ctx, func(ctx context.Context) (int64, error) {
+		return r.purger.PurgeIdempotencyKeys(ctx, r.cfg.BatchSize)
+	})
+	r.metrics.ObserveIdempotencyKeys(keys)
+	r.metrics.ObserveRun(time.Since(start), err)
+	if err != nil {
+		log.Error("failed to purge idempotency keys", slog.Int64("idempotency_keys_count", keys), logger.StringError(err))
+		return
+	}
+
+	log.Info(
+		"success to purge outdated data",
+		slog.String("action", action),
+		slog.Int64("messages_count", messages),
+		slog.Int64("outbox_events_count", outboxEvents),
+		slog.Int64("idempotency_keys_count", keys),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+// purgeBatches вызывает fn, пока она удаляет полные пакеты, и возвращает общее количество удаленных строк.
+func (r *Retention) purgeBatches(ctx context.Context, fn func(ctx context.Context) (int64, error)) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := fn(ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		if n < int64(r.cfg.BatchSize) {
+			return total, nil
+		}
+	}
+
+	return total, ctx.Err()
+}
@@ -0,0 +1,67 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/pkg/consistency"
+)
+
+// statusTransitions содержит допустимые переходы между статусами сообщения.
+//
+// Обработчик может подтвердить получение или завершить обработку сообщения раньше,
+// чем будет зафиксирована отправка события, поэтому из created допустимы все последующие статусы.
+// Статусы processed, failed и cancelled конечные.
+var statusTransitions = map[string][]string{
+	models.MessageStatusCreated: {
+		models.MessageStatusQueued,
+		models.MessageStatusProcessing,
+		models.MessageStatusProcessed,
+		models.MessageStatusFailed,
+		models.MessageStatusCancelled,
+	},
+	models.MessageStatusQueued: {
+		models.MessageStatusProcessing,
+		models.MessageStatusProcessed,
+		models.MessageStatusFailed,
+		models.MessageStatusCancelled,
+	},
+	models.MessageStatusProcessing: {
+		models.MessageStatusProcessed,
+		models.MessageStatusFailed,
+	},
+}
+
+// canTransition проверяет, допустим ли переход сообщения из статуса from в статус to.
+func canTransition(from, to string) bool {
+	return slices.Contains(statusTransitions[from], to)
+}
+
+// completed проверяет, завершена ли обработка сообщения с указанным статусом.
+func completed(status string) bool {
+	return status == models.MessageStatusProcessed || status == models.MessageStatusFailed
+}
+
+// transition переводит сообщение в новый статус, если переход из текущего статуса допустим.
+//
+// Возвращает models.ErrMessageAlreadyProcessed при повторном завершении обработки сообщения
+// и models.ErrInvalidStatusTransition при любом другом недопустимом переходе.
+func (m *Message) transition(ctx context.Context, id uint64, u models.MessageStatusUpdate) error {
+	// Текущий статус читается с основной базы данных, поскольку на реплике он может быть устаревшим.
+	message, err := m.messageProvider.Message(consistency.WithPrimary(ctx), id)
+	if err != nil {
+		return err
+	}
+
+	if !canTransition(message.Status, u.Status) {
+		if completed(message.Status) && completed(u.Status) {
+			return models.ErrMessageAlreadyProcessed
+		}
+
+		return fmt.Errorf("%w: %s -> %s", models.ErrInvalidStatusTransition, message.Status, u.Status)
+	}
+
+	return m.messageUpdater.UpdateMessageStatus(ctx, id, message.Status, u)
+}
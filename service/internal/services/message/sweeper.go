@@ -0,0 +1,154 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+	"github.com/sedonn/message-service/internal/pkg/logger"
+)
+
+// StuckMessageProvider описывает поведение объекта, который обеспечивает захват зависших сообщений.
+type StuckMessageProvider interface {
+	// ClaimStuckMessages захватывает отправленные обработчику, но не обработанные сообщения,
+	// последняя отправка которых была раньше before, и сдвигает время их последней отправки.
+	// Одно сообщение не может быть захвачено одновременно несколькими вызовами.
+	ClaimStuckMessages(ctx context.Context, before time.Time, limit int) ([]models.Message, error)
+
+	// MarkMessageRedispatched увеличивает счетчик отправок сообщения, которое еще не обработано.
+	MarkMessageRedispatched(ctx context.Context, id uint64) error
+}
+
+// MessageEventProducer описывает поведение объекта, который отправляет события сообщений.
+type MessageEventProducer interface {
+	// NotifyStartProcessingMessage отправляет событие старта обработки сообщения.
+	NotifyStartProcessingMessage(ctx context.Context, e events.StartProcessingMessage) error
+}
+
+// Sweeper периодически повторно отправляет события старта обработки зависших сообщений.
+// Сообщения, событие старта обработки которых еще не отправлено из outbox, не считаются зависшими.
+// Сообщения, которые не были обработаны после максимального количества повторных отправок,
+// отмечаются как необработанные с ошибкой. Неудачная отправка не считается попыткой,
+// поэтому недоступность брокера не исчерпывает попытки отправки.
+type Sweeper struct {
+	log                  *slog.Logger
+	cfg                  *config.SweeperConfig
+	messages             *Message
+	stuckMessageProvider StuckMessageProvider
+	eventProducer        MessageEventProducer
+	wg                   *sync.WaitGroup
+}
+
+// NewSweeper создает новый Sweeper.
+func NewSweeper(
+	log *slog.Logger,
+	cfg *config.SweeperConfig,
+	m *Message,
+	smp StuckMessageProvider,
+	mep MessageEventProducer,
+) *Sweeper {
+	return &Sweeper{
+		log:                  log,
+		cfg:                  cfg,
+		messages:             m,
+		stuckMessageProvider: smp,
+		eventProducer:        mep,
+		wg:                   &sync.WaitGroup{},
+	}
+}
+
+// Run запускает фоновую проверку зависших сообщений. Проверка прекращается при отмене контекста.
+func (s *Sweeper) Run(ctx context.Context) {
+	const op = "sweeper.Run"
+	log := s.log.With(slog.String("op", op))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.sweep(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Info("stuck messages sweeper start working")
+}
+
+// Stop ожидает завершения текущей проверки зависших сообщений.
+func (s *Sweeper) Stop() {
+	const op = "sweeper.Stop"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("stopping stuck messages sweeper")
+	s.wg.Wait()
+	log.Info("stuck messages sweeper stopped")
+}
+
+// sweep захватывает очередной пакет зависших сообщений и повторно отправляет их
+// или отмечает необработанными, если попытки отправки исчерпаны.
+func (s *Sweeper) sweep(ctx context.Context) {
+	const op = "sweeper.sweep"
+	log := s.log.With(slog.String("op", op))
+
+	messages, err := s.stuckMessageProvider.ClaimStuckMessages(ctx, time.Now().Add(-s.cfg.Timeout), s.cfg.BatchSize)
+	if err != nil {
+		log.Error("failed to claim stuck messages", logger.StringError(err))
+		return
+	}
+
+	if len(messages) > 0 {
+		log.Info("stuck messages found", slog.Int("messages_count", len(messages)))
+	}
+
+	for _, m := range messages {
+		log := log.With(slog.Uint64("message_id", m.ID), slog.Int("attempt", m.DispatchAttempts+1))
+
+		if m.DispatchAttempts >= s.cfg.MaxAttempts {
+			if err := s.fail(ctx, m); err != nil {
+				log.Error("failed to mark stuck message as failed", logger.StringError(err))
+				continue
+			}
+
+			log.Warn("stuck message marked as failed, dispatch attempts exhausted")
+			continue
+		}
+
+		e := events.StartProcessingMessage{ID: m.ID, Content: m.Content}
+		if err := s.eventProducer.NotifyStartProcessingMessage(ctx, e); err != nil {
+			log.Error("failed to redispatch stuck message", logger.StringError(err))
+			continue
+		}
+
+		if err := s.stuckMessageProvider.MarkMessageRedispatched(ctx, m.ID); err != nil {
+			log.Error("failed to count stuck message dispatch attempt", logger.StringError(err))
+			continue
+		}
+
+		log.Info("stuck message redispatched")
+	}
+}
+
+// fail отмечает зависшее сообщение необработанным с ошибкой.
+func (s *Sweeper) fail(ctx context.Context, m models.Message) error {
+	now := time.Now()
+	reason := fmt.Sprintf("processing is not completed after %d dispatch attempts", s.cfg.MaxAttempts)
+
+	return s.messages.transition(ctx, m.ID, models.MessageStatusUpdate{
+		Status:      models.MessageStatusFailed,
+		ProcessedAt: &now,
+		ErrorReason: &reason,
+	})
+}
@@ -0,0 +1,118 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sedonn/message-service/internal/config"
+	"github.com/sedonn/message-service/internal/domain/events"
+	"github.com/sedonn/message-service/internal/domain/models"
+)
+
+// fakeStuckMessageProvider это StuckMessageProvider, который считает зависшими все отправленные сообщения.
+type fakeStuckMessageProvider struct {
+	repo *fakeRepository
+}
+
+func (p *fakeStuckMessageProvider) ClaimStuckMessages(_ context.Context, _ time.Time, _ int) ([]models.Message, error) {
+	var messages []models.Message
+	for _, m := range p.repo.messages {
+		if m.Status == models.MessageStatusQueued || m.Status == models.MessageStatusProcessing {
+			messages = append(messages, m)
+		}
+	}
+
+	return messages, nil
+}
+
+func (p *fakeStuckMessageProvider) MarkMessageRedispatched(_ context.Context, id uint64) error {
+	m := p.repo.messages[id]
+	m.DispatchAttempts++
+	p.repo.messages[id] = m
+
+	return nil
+}
+
+// fakeEventProducer это MessageEventProducer, который запоминает ID сообщений отправленных событий.
+type fakeEventProducer struct {
+	err  error
+	sent []uint64
+}
+
+func (p *fakeEventProducer) NotifyStartProcessingMessage(_ context.Context, e events.StartProcessingMessage) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	p.sent = append(p.sent, e.ID)
+
+	return nil
+}
+
+func newTestSweeper(r *fakeRepository, mep MessageEventProducer) *Sweeper {
+	return NewSweeper(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		&config.SweeperConfig{Timeout: time.Minute, BatchSize: 10, MaxAttempts: 3},
+		newTestMessage(r),
+		&fakeStuckMessageProvider{repo: r},
+		mep,
+	)
+}
+
+func TestSweepRedispatch(t *testing.T) {
+	r := &fakeRepository{messages: map[uint64]models.Message{1: {ID: 1, Status: models.MessageStatusQueued}}}
+	mep := &fakeEventProducer{}
+
+	newTestSweeper(r, mep).sweep(context.Background())
+
+	if !slices.Equal(mep.sent, []uint64{1}) {
+		t.Errorf("redispatched messages = %v, want [1]", mep.sent)
+	}
+
+	m := r.messages[1]
+	if m.Status != models.MessageStatusQueued || m.DispatchAttempts != 1 {
+		t.Errorf("message status = %s, dispatch attempts = %d, want %s and 1",
+			m.Status, m.DispatchAttempts, models.MessageStatusQueued)
+	}
+}
+
+func TestSweepRedispatchFailed(t *testing.T) {
+	r := &fakeRepository{messages: map[uint64]models.Message{1: {ID: 1, Status: models.MessageStatusQueued}}}
+	s := newTestSweeper(r, &fakeEventProducer{err: errors.New("broker is unavailable")})
+
+	// Недоступность брокера дольше максимального количества попыток не завершает обработку сообщения.
+	for range s.cfg.MaxAttempts + 1 {
+		s.sweep(context.Background())
+	}
+
+	m := r.messages[1]
+	if m.Status != models.MessageStatusQueued || m.DispatchAttempts != 0 {
+		t.Errorf("message status = %s, dispatch attempts = %d, want %s and 0",
+			m.Status, m.DispatchAttempts, models.MessageStatusQueued)
+	}
+}
+
+func TestSweepAttemptsExhausted(t *testing.T) {
+	r := &fakeRepository{messages: map[uint64]models.Message{1: {ID: 1, Status: models.MessageStatusProcessing}}}
+	mep := &fakeEventProducer{}
+	s := newTestSweeper(r, mep)
+
+	for range s.cfg.MaxAttempts + 1 {
+		s.sweep(context.Background())
+	}
+
+	if len(mep.sent) != s.cfg.MaxAttempts {
+		t.Errorf("redispatches = %d, want %d", len(mep.sent), s.cfg.MaxAttempts)
+	}
+
+	m := r.messages[1]
+	if m.Status != models.MessageStatusFailed || m.ErrorReason == nil || m.ProcessedAt == nil {
+		t.Errorf("message status = %s, error reason = %v, processed at = %v, want failed with reason",
+			m.Status, m.ErrorReason, m.ProcessedAt)
+	}
+}